@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hflabstesttask/gdocs"
+	"io"
+)
+
+// Runs gdocs.CheckAccess for the documents of all the jobs, printing a line per step, and fails with the first failed step.
+func runCheck(ctx context.Context, cfg *Config, w io.Writer) error {
+	docIds := []string{}
+	for _, job := range cfg.jobs() {
+		docId := job.DocumentId
+		if docId == "" {
+			var err error
+			docId, err = gdocs.StoredDocumentId(job.DocumentIdPath, job.URL)
+			if err != nil {
+				fmt.Fprintf(w, "FAIL  document     %v\n", err)
+				return withExitCode(EXIT_AUTH, err)
+			}
+		}
+		docIds = append(docIds, docId)
+	}
+
+	for _, result := range gdocs.CheckAccess(ctx, cfg.authOptions(), docIds) {
+		if result.Err != nil {
+			fmt.Fprintf(w, "FAIL  %-11v  %v\n", result.Step, result.Err)
+			return withExitCode(EXIT_AUTH, fmt.Errorf("Check of the %v failed: %w", result.Step, result.Err))
+		}
+		fmt.Fprintf(w, "OK    %-11v  %v\n", result.Step, result.Detail)
+	}
+
+	fmt.Fprintln(w, "OK")
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/syncer"
+	"log/slog"
+)
+
+// Clears the documents of all the jobs without scraping anything, asking first like before a write.
+// The jobs go one by one, so that the prompts don't interleave.
+func runClearOnly(ctx context.Context, cfg *Config, srv *docs.Service) error {
+	for _, job := range cfg.jobs() {
+		s, err := syncer.New(cfg.syncOptions(job, nil, slog.Default().With("url", job.URL)))
+		if err != nil {
+			return err
+		}
+		doc, err := s.OpenDocument(ctx, srv)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return withExitCode(EXIT_AUTH, fmt.Errorf("%w: no document is stored for %v in %v", gdocs.ErrDocumentUnavailable, job.URL, job.DocumentIdPath))
+		}
+
+		if err := s.Clear(ctx, srv, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,917 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"gopkg.in/yaml.v3"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"hflabstesttask/syncer"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	// Bundles the timezone database, so that -timezone works in containers without one.
+	_ "time/tzdata"
+)
+
+const CONFLUENCE_URL = syncer.CONFLUENCE_URL
+const CONFLUENCE_BASE = "https://confluence.hflabs.ru"
+const CREDENTIALS_PATH = syncer.CREDENTIALS_PATH
+const DOCUMENT_ID_PATH = syncer.DOCUMENT_ID_PATH
+const TOKEN_PATH = syncer.TOKEN_PATH
+const HASH_PATH = syncer.HASH_PATH
+const RESUME_PATH = syncer.RESUME_PATH
+const MODIFIED_PATH = "last_modified.json"
+
+const DOCUMENT_TITLE = syncer.DOCUMENT_TITLE
+const FETCH_ATTEMPTS = syncer.FETCH_ATTEMPTS
+const FETCH_MAX_ELAPSED = syncer.FETCH_MAX_ELAPSED
+const FETCH_TIMEOUT = syncer.FETCH_TIMEOUT
+const LOG_LEVEL = "info"
+const STRIPE_COLOR = "#f3f3f3"
+const JOBS_PARALLEL = 4
+
+const DOCS_QPS = syncer.DOCS_QPS
+
+type Config struct {
+	// Print the build version and exit, only settable by the flag.
+	Version bool `yaml:"-"`
+
+	URL string `yaml:"url"`
+	// Whether url was given in the config file or with -url, as URL always has a value.
+	urlSet bool
+	// Id of the page to scrape instead of URL, its URL is built from ConfluenceBase.
+	PageId         string `yaml:"page_id"`
+	ConfluenceBase string `yaml:"confluence_base"`
+	// Saved HTML page to scrape instead of URL.
+	HtmlFile string `yaml:"html_file"`
+	// Where to save the fetched HTML page for debugging.
+	SaveHtml string `yaml:"save_html"`
+
+	// Also scrape the pages linked from the page, this many levels down, into the same document.
+	CrawlDepth int `yaml:"crawl_depth"`
+	// CSS selector of the links to follow when crawling.
+	CrawlSelector string `yaml:"crawl_selector"`
+
+	// Pages to process instead of URL, each written to its own document.
+	Jobs []Job `yaml:"jobs"`
+	// Maximum number of jobs processed at once.
+	Parallel int `yaml:"parallel"`
+
+	// Insert a "Last updated" line with the time and the source above the tables.
+	Timestamp bool `yaml:"timestamp"`
+	// IANA name of the timezone of the timestamp and the title, such as Europe/Moscow, empty means the local one.
+	Timezone string `yaml:"timezone"`
+
+	// text/template of the title, see syncer.Options.Title for the fields.
+	DocumentTitle   string `yaml:"document_title"`
+	CredentialsPath string `yaml:"credentials_path"`
+	TokenPath       string `yaml:"token_path"`
+	DocumentIdPath  string `yaml:"document_id_path"`
+	// Files storing the ids of more documents the tables of url are written to, after one scrape.
+	MirrorDocumentIdPaths []string `yaml:"mirror_document_id_paths"`
+	// Document id, OAuth client secret or service account key, and OAuth token from the environment,
+	// used instead of the files above, see applyEnv.
+	DocumentId  string `yaml:"-"`
+	Credentials string `yaml:"-"`
+	Token       string `yaml:"-"`
+	// Also give an existing document the rendered title.
+	Rename bool `yaml:"rename"`
+	// Drive folder new documents are created in, such as a Shared Drive folder, empty means the root of My Drive.
+	DriveFolderId string `yaml:"drive_folder_id"`
+	// Only ask Google for read access, the document is checked but never written.
+	ReadOnly bool `yaml:"read_only"`
+	// File with the hash of the tables last written to each document.
+	HashPath string `yaml:"hash_path"`
+	// Insert the tables one by one and log them to ResumePath, so that a rerun goes on after the last inserted one.
+	Resume     bool   `yaml:"resume"`
+	ResumePath string `yaml:"resume_path"`
+	// Skip a page Confluence reports unchanged since the last run, the times are stored in ModifiedPath.
+	Since        bool   `yaml:"since"`
+	ModifiedPath string `yaml:"modified_path"`
+	// Rewrite the document even if the tables haven't changed since the last run.
+	Force bool `yaml:"force"`
+	// Clear a document with content without asking, required when stdin isn't a terminal.
+	Yes bool `yaml:"yes"`
+
+	FetchAttempts   int           `yaml:"fetch_attempts"`
+	FetchMaxElapsed time.Duration `yaml:"fetch_max_elapsed"`
+	FetchTimeout    time.Duration `yaml:"fetch_timeout"`
+
+	ConfluenceUser  string `yaml:"confluence_user"`
+	ConfluencePass  string `yaml:"confluence_pass"`
+	ConfluenceToken string `yaml:"confluence_token"`
+	// User-Agent of the Confluence requests, USER_AGENT by default.
+	UserAgent string `yaml:"user_agent"`
+	// Extra headers of the Confluence requests.
+	Headers map[string]string `yaml:"headers"`
+	// Proxy for the Confluence requests instead of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+	Proxy string `yaml:"proxy"`
+	// Extra CA certificates for the Confluence server, PEM.
+	CACert             string `yaml:"ca_cert"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+
+	TableIndex   int    `yaml:"table_index"`
+	TableCaption string `yaml:"table_caption"`
+
+	// Comma-separated header names or 0-based indices of the columns to keep, empty keeps all of them.
+	Columns string `yaml:"columns"`
+
+	// Regexp a cell of a data row has to match for the row to be kept, empty keeps all the rows.
+	RowFilter string `yaml:"row_filter"`
+	// Keep the data rows without a matching cell instead.
+	InvertRowFilter bool `yaml:"invert_row_filter"`
+
+	// Drop rows identical to the row right above them.
+	DedupRows bool `yaml:"dedup_rows"`
+	// Keep at most this many data rows of every table, 0 means no limit.
+	MaxRows int `yaml:"max_rows"`
+
+	// Merge consecutive tables with the same first row, before selecting tables by index.
+	MergeContinuations bool `yaml:"merge_continuations"`
+
+	// Go on when the page has no tables, which leaves the document empty unless appending.
+	AllowEmpty bool `yaml:"allow_empty"`
+
+	// Print the scraped tables and exit without touching the document.
+	ListTables bool `yaml:"list_tables"`
+
+	// One of gdoc, csv, json, md or txt.
+	Format string `yaml:"format"`
+	// Path of the json, md and txt output, "-" is stdout, or the directory of the csv output, "-" is the current one.
+	Output string `yaml:"output"`
+
+	DryRun bool   `yaml:"dry_run"`
+	CsvDir string `yaml:"csv_dir"`
+	// Path of the Markdown output, "-" is stdout.
+	Markdown string `yaml:"markdown"`
+	// Path of the JSON output, "-" is stdout.
+	JsonOut string `yaml:"json_out"`
+	// Path of the plain-text output, "-" is stdout.
+	TxtOut string `yaml:"txt_out"`
+
+	// Check the credentials, the token and access to the documents, then exit without scraping or writing anything.
+	Check bool `yaml:"-"`
+	// Clear the documents, then exit without scraping anything.
+	ClearOnly bool `yaml:"-"`
+
+	// File with the tables of the last run of each page, a cell-level diff against them is printed before writing.
+	// Empty disables the diff.
+	DiffPath string `yaml:"diff_path"`
+	// Print the diff as JSON instead of text.
+	DiffJson bool `yaml:"diff_json"`
+
+	// Print the summary of the run to stderr as JSON instead of key=value pairs.
+	StatsJson bool `yaml:"stats_json"`
+
+	// One of debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+	// File the logs are appended to, empty means stderr.
+	LogFile string `yaml:"log_file"`
+
+	// One of auto, oauth or service-account.
+	AuthMode              string `yaml:"auth_mode"`
+	ServiceAccountSubject string `yaml:"service_account_subject"`
+
+	DocsQPS float64 `yaml:"docs_qps"`
+	// Log every Google API request and response with their JSON bodies, the credentials are redacted.
+	Trace bool `yaml:"trace"`
+
+	// Heading inserted above every table, {n} is replaced with the 1-based table number. Empty inserts none.
+	TableHeading string `yaml:"table_heading"`
+	// Insert the heading preceding each table on the page as a section heading above it.
+	PageHeadings bool `yaml:"page_headings"`
+	// Insert a list of links to the headings at the beginning of the document after the tables.
+	Toc bool `yaml:"toc"`
+
+	// CSS selector of the tables on the page.
+	Selector string `yaml:"selector"`
+	// CSS selector of the elements the tables are searched in, empty searches the whole page.
+	ContainerSelector string `yaml:"container_selector"`
+
+	PreserveFormatting bool `yaml:"preserve_formatting"`
+	// Align the text of the cells like on the page.
+	PreserveAlignment bool `yaml:"preserve_alignment"`
+
+	// Keep the current document content and add the tables after it instead of replacing it.
+	Append bool `yaml:"append"`
+	// Keep the current document content and put the tables in place of the occurrences of this text.
+	Placeholder string `yaml:"placeholder"`
+	// Replace these tokens of the document with cells of the tables instead of inserting the tables.
+	Replacements []Replacement `yaml:"replacements"`
+
+	// Comma separated column widths in points, or "equal" to distribute the width evenly.
+	ColWidths string `yaml:"col_widths"`
+
+	// Bold the first row of every table, rows of th cells are bold anyway.
+	BoldHeader bool `yaml:"bold_header"`
+
+	// Color every other row with StripeColor, #rrggbb.
+	Zebra       bool   `yaml:"zebra"`
+	StripeColor string `yaml:"stripe_color"`
+
+	// Borders of the table cells, the default ones are left as they are unless one of these is set.
+	BorderNone bool `yaml:"border_none"`
+	// #rrggbb, black by default.
+	BorderColor string `yaml:"border_color"`
+	// In points, 1 by default.
+	BorderWidth float64 `yaml:"border_width"`
+	// One of solid, dot or dash, solid by default.
+	BorderStyle string `yaml:"border_style"`
+
+	// Reject tables with rows of different lengths instead of padding them.
+	StrictColumns bool `yaml:"strict_columns"`
+
+	NormalizeCells bool `yaml:"normalize_cells"`
+	KeepLineBreaks bool `yaml:"keep_line_breaks"`
+	// Turn every <br> and block element boundary into a single newline, whether or not the cells are normalized.
+	BrAsNewline bool `yaml:"br_as_newline"`
+	// One of text, lines or bullets, see scrape.LISTS_*.
+	Lists string `yaml:"lists"`
+	// Truncate cells longer than this many characters with an ellipsis, 0 means no limit.
+	MaxCellChars int `yaml:"max_cell_chars"`
+	// Keep the alt text of the images in the cells and insert the images Google Docs can fetch into the document.
+	Images bool `yaml:"images"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		URL:             CONFLUENCE_URL,
+		ConfluenceBase:  CONFLUENCE_BASE,
+		DocumentTitle:   DOCUMENT_TITLE,
+		CredentialsPath: CREDENTIALS_PATH,
+		TokenPath:       TOKEN_PATH,
+		DocumentIdPath:  DOCUMENT_ID_PATH,
+		HashPath:        HASH_PATH,
+		ResumePath:      RESUME_PATH,
+		ModifiedPath:    MODIFIED_PATH,
+		Parallel:        JOBS_PARALLEL,
+		FetchAttempts:   FETCH_ATTEMPTS,
+		FetchMaxElapsed: FETCH_MAX_ELAPSED,
+		FetchTimeout:    FETCH_TIMEOUT,
+		UserAgent:       userAgent(),
+		TableIndex:      -1,
+		LogLevel:        LOG_LEVEL,
+		AuthMode:        gdocs.AUTH_MODE_AUTO,
+		DocsQPS:         DOCS_QPS,
+		NormalizeCells:  true,
+		Lists:           scrape.LISTS_TEXT,
+		PageHeadings:    true,
+		Selector:        scrape.TABLE_SELECTOR,
+		CrawlSelector:   scrape.CRAWL_SELECTOR,
+		StripeColor:     STRIPE_COLOR,
+		Format:          FORMAT_GDOC,
+		Output:          "-",
+	}
+}
+
+// Reads a YAML (or JSON) config file, keys missing from the file keep their default values.
+func loadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config file: %v", err)
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("Unable to parse config file %v: %v", path, err)
+	}
+	keys := map[string]any{}
+	if err := yaml.Unmarshal(b, &keys); err == nil {
+		_, cfg.urlSet = keys["url"]
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	missing := []string{}
+	if cfg.URL == "" {
+		missing = append(missing, "url")
+	}
+	if cfg.DocumentTitle == "" {
+		missing = append(missing, "document_title")
+	}
+	if cfg.CredentialsPath == "" {
+		missing = append(missing, "credentials_path")
+	}
+	if cfg.TokenPath == "" {
+		missing = append(missing, "token_path")
+	}
+	if cfg.DocumentIdPath == "" {
+		missing = append(missing, "document_id_path")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Missing required config values: %v", strings.Join(missing, ", "))
+	}
+
+	if err := cfg.validateJobs(); err != nil {
+		return err
+	}
+
+	if _, err := template.New("title").Parse(cfg.DocumentTitle); err != nil {
+		return fmt.Errorf("Invalid document_title template: %v", err)
+	}
+
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("Invalid timezone %q: %v", cfg.Timezone, err)
+	}
+
+	if cfg.FetchAttempts < 1 {
+		return fmt.Errorf("fetch_attempts must be at least 1, got %v", cfg.FetchAttempts)
+	}
+
+	if cfg.FetchMaxElapsed <= 0 {
+		return fmt.Errorf("fetch_max_elapsed must be positive, got %v", cfg.FetchMaxElapsed)
+	}
+
+	if cfg.FetchTimeout <= 0 {
+		return fmt.Errorf("fetch_timeout must be positive, got %v", cfg.FetchTimeout)
+	}
+
+	if cfg.HtmlFile != "" && cfg.urlSet {
+		return fmt.Errorf("url and html_file are mutually exclusive")
+	}
+
+	if cfg.CrawlDepth < 0 {
+		return fmt.Errorf("crawl_depth must not be negative, got %v", cfg.CrawlDepth)
+	}
+	if cfg.CrawlDepth > 0 && (cfg.HtmlFile != "" || cfg.SaveHtml != "") {
+		return fmt.Errorf("crawl_depth can't be used with html_file or save_html, it fetches several pages")
+	}
+	if err := scrape.ValidateSelector(cfg.CrawlSelector); err != nil {
+		return err
+	}
+
+	if cfg.Proxy != "" {
+		if proxyURL, err := url.Parse(cfg.Proxy); err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("Invalid proxy %q, expected a URL such as http://proxy:3128", cfg.Proxy)
+		}
+	}
+
+	if cfg.ConfluenceToken != "" && (cfg.ConfluenceUser != "" || cfg.ConfluencePass != "") {
+		return fmt.Errorf("confluence_token and confluence_user/confluence_pass are mutually exclusive")
+	}
+
+	switch cfg.AuthMode {
+	case gdocs.AUTH_MODE_AUTO, gdocs.AUTH_MODE_OAUTH, gdocs.AUTH_MODE_SERVICE_ACCOUNT:
+	default:
+		return fmt.Errorf("Invalid auth_mode %q, expected auto, oauth or service-account", cfg.AuthMode)
+	}
+
+	switch cfg.Format {
+	case FORMAT_GDOC, FORMAT_CSV, FORMAT_JSON, FORMAT_MARKDOWN, FORMAT_TEXT:
+	default:
+		return fmt.Errorf("Invalid format %q, expected gdoc, csv, json, md or txt", cfg.Format)
+	}
+
+	if cfg.ReadOnly && cfg.Rename {
+		return fmt.Errorf("read_only and rename are mutually exclusive, renaming needs write access")
+	}
+	// Nothing else is allowed with read-only access, a run writing the document would only fail at the end.
+	if cfg.ReadOnly && cfg.Format == FORMAT_GDOC && !cfg.DryRun && !cfg.ListTables && !cfg.Check {
+		return fmt.Errorf("read_only can't write the document, use it with dry_run, list_tables or -check")
+	}
+	if cfg.ReadOnly && cfg.DriveFolderId != "" {
+		return fmt.Errorf("read_only and drive_folder_id are mutually exclusive, creating documents needs write access")
+	}
+
+	if cfg.ClearOnly && (cfg.ReadOnly || cfg.DryRun || cfg.ListTables || cfg.Check || cfg.Format != FORMAT_GDOC) {
+		return fmt.Errorf("-clear-only writes to Google Docs, so it can't be used with read_only, dry_run, list_tables, -check or another format")
+	}
+
+	if cfg.Placeholder != "" && cfg.Append {
+		return fmt.Errorf("append and placeholder are mutually exclusive")
+	}
+	// Neither puts anything above the tables for the line to go in.
+	if cfg.Timestamp && (cfg.Placeholder != "" || len(cfg.Replacements) > 0) {
+		return fmt.Errorf("timestamp is inserted above the tables, so it can't be used with placeholder or replacements")
+	}
+	if cfg.Resume && (cfg.Placeholder != "" || len(cfg.Replacements) > 0) {
+		return fmt.Errorf("resume only works when the tables are inserted at the end, not with placeholder or replacements")
+	}
+	// The child pages change without the page itself changing.
+	if cfg.Since && cfg.CrawlDepth > 0 {
+		return fmt.Errorf("since only checks the page itself, so it can't be used with crawl_depth")
+	}
+	if cfg.Since && cfg.ModifiedPath == "" {
+		return fmt.Errorf("since needs a modified_path")
+	}
+	if cfg.Resume && cfg.ResumePath == "" {
+		return fmt.Errorf("resume needs a resume_path")
+	}
+	// The document keeps its content otherwise, with the headings the list would link to.
+	if cfg.Toc && (cfg.Append || cfg.Placeholder != "" || len(cfg.Replacements) > 0) {
+		return fmt.Errorf("toc is inserted into a cleared document, so it can't be used with append, placeholder or replacements")
+	}
+	if len(cfg.Replacements) > 0 && (cfg.Placeholder != "" || cfg.Append) {
+		return fmt.Errorf("replacements don't insert tables, so they can't be used with append or placeholder")
+	}
+	tokens := map[string]bool{}
+	for i, r := range cfg.Replacements {
+		if r.Token == "" {
+			return fmt.Errorf("Replacement #%v needs a token", i)
+		}
+		if tokens[r.Token] {
+			return fmt.Errorf("The token %v is replaced twice", r.Token)
+		}
+		tokens[r.Token] = true
+	}
+
+	if _, err := regexp.Compile(cfg.RowFilter); err != nil {
+		return fmt.Errorf("Invalid row_filter %q: %v", cfg.RowFilter, err)
+	}
+
+	switch cfg.Lists {
+	case scrape.LISTS_TEXT, scrape.LISTS_LINES, scrape.LISTS_BULLETS:
+	default:
+		return fmt.Errorf("Invalid lists %q, expected text, lines or bullets", cfg.Lists)
+	}
+
+	if cfg.MaxRows < 0 {
+		return fmt.Errorf("max_rows must not be negative, got %v", cfg.MaxRows)
+	}
+
+	if cfg.MaxCellChars < 0 {
+		return fmt.Errorf("max_cell_chars must not be negative, got %v", cfg.MaxCellChars)
+	}
+
+	if cfg.DocsQPS < 0 {
+		return fmt.Errorf("docs_qps must not be negative, got %v", cfg.DocsQPS)
+	}
+
+	if err := scrape.ValidateSelector(cfg.Selector); err != nil {
+		return err
+	}
+	if cfg.ContainerSelector != "" {
+		if err := scrape.ValidateSelector(cfg.ContainerSelector); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseColumnWidths(cfg.ColWidths); err != nil {
+		return err
+	}
+
+	if cfg.Zebra {
+		if _, err := gdocs.ParseHexColor(cfg.StripeColor); err != nil {
+			return fmt.Errorf("Invalid stripe_color: %v", err)
+		}
+	}
+
+	if _, err := cfg.borders(); err != nil {
+		return err
+	}
+
+	level := slog.Level(0)
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return fmt.Errorf("Invalid log_level %q, expected debug, info, warn or error", cfg.LogLevel)
+	}
+
+	return nil
+}
+
+func (cfg *Config) validateJobs() error {
+	if cfg.Parallel < 1 {
+		return fmt.Errorf("parallel must be at least 1, got %v", cfg.Parallel)
+	}
+
+	if len(cfg.MirrorDocumentIdPaths) > 0 {
+		if len(cfg.Jobs) > 0 {
+			return fmt.Errorf("mirror_document_id_paths and jobs are mutually exclusive, add jobs with the same url instead")
+		}
+		if cfg.Format != FORMAT_GDOC {
+			return fmt.Errorf("Mirror documents can only be written to Google Docs, got format %q", cfg.Format)
+		}
+
+		seen := map[string]bool{cfg.DocumentIdPath: true}
+		for _, path := range cfg.MirrorDocumentIdPaths {
+			if path == "" {
+				return fmt.Errorf("Document id paths can't be empty")
+			}
+			if seen[path] {
+				return fmt.Errorf("Document id path %v is given twice", path)
+			}
+			seen[path] = true
+		}
+	}
+
+	if len(cfg.Jobs) == 0 {
+		return nil
+	}
+
+	if cfg.HtmlFile != "" {
+		return fmt.Errorf("html_file and jobs are mutually exclusive, use file:// URLs in the jobs")
+	}
+
+	// The exports of the jobs would overwrite each other.
+	if cfg.CsvDir != "" || cfg.Markdown != "" || cfg.JsonOut != "" || cfg.TxtOut != "" || cfg.SaveHtml != "" {
+		return fmt.Errorf("csv_dir, markdown, json_out, txt_out and save_html can't be used with jobs")
+	}
+	if cfg.Format != FORMAT_GDOC {
+		return fmt.Errorf("Jobs can only be written to Google Docs, got format %q", cfg.Format)
+	}
+	if cfg.DocumentId != "" {
+		return fmt.Errorf("%v names a single document and can't be used with jobs", ENV_DOCUMENT_ID)
+	}
+
+	// The document ids are stored by URL, so jobs can share a document_id_path but not a URL in it.
+	seen := map[Job]bool{}
+	for i, job := range cfg.Jobs {
+		if job.URL == "" || job.DocumentIdPath == "" {
+			return fmt.Errorf("Job #%v needs both url and document_id_path", i)
+		}
+		if seen[job] {
+			return fmt.Errorf("Jobs share the url %v and the document_id_path %v", job.URL, job.DocumentIdPath)
+		}
+		seen[job] = true
+	}
+
+	return nil
+}
+
+// Collects the repeated -header KEY=VALUE flags.
+type headersFlag struct {
+	headers *map[string]string
+}
+
+func (f headersFlag) String() string {
+	if f.headers == nil {
+		return ""
+	}
+
+	pairs := []string{}
+	for key, value := range *f.headers {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func (f headersFlag) Set(value string) error {
+	key, headerValue, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("Expected KEY=VALUE, got %q", value)
+	}
+
+	if *f.headers == nil {
+		*f.headers = map[string]string{}
+	}
+	(*f.headers)[key] = strings.TrimSpace(headerValue)
+	return nil
+}
+
+// Defines the command line flags on top of cfg, so that the values already in cfg act as defaults.
+func newFlagSet(cfg *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.BoolVar(&cfg.Version, "version", cfg.Version, "Print the version and exit")
+	fs.StringVar(configPath, "config", "", "Path to a YAML/JSON config file, flags override its values")
+	fs.StringVar(&cfg.URL, "url", cfg.URL, "Confluence page to scrape tables from")
+	fs.StringVar(&cfg.PageId, "page-id", cfg.PageId, "Id of the Confluence page to scrape instead of -url, the URL is built from -confluence-base")
+	fs.StringVar(&cfg.ConfluenceBase, "confluence-base", cfg.ConfluenceBase, "Base URL of Confluence for -page-id")
+	fs.StringVar(&cfg.HtmlFile, "html-file", cfg.HtmlFile, "Scrape tables from this saved HTML file instead of the Confluence URL, a file:// URL works too")
+	fs.StringVar(&cfg.SaveHtml, "save-html", cfg.SaveHtml, "Save the fetched HTML page to this file and its status and headers to the file plus .headers")
+	fs.IntVar(&cfg.CrawlDepth, "crawl-depth", cfg.CrawlDepth, "Also scrape the child pages linked from the page into the same document, this many levels down, 0 scrapes the page alone")
+	fs.StringVar(&cfg.CrawlSelector, "crawl-selector", cfg.CrawlSelector, "CSS selector of the links to child pages to follow when crawling, only links to the same host are followed")
+	fs.Var(jobsFlag{&cfg.Jobs}, "job", "Scrape URL into the document whose id is stored in DOCUMENT_ID_PATH, as URL=DOCUMENT_ID_PATH, can be repeated")
+	fs.IntVar(&cfg.Parallel, "parallel", cfg.Parallel, "Maximum number of jobs processed at once")
+	fs.StringVar(&cfg.DocumentTitle, "title", cfg.DocumentTitle, "Title of the Google Docs document when it is created, a template with {{.Date}}, {{.Time}}, {{.URL}} and {{.Tables}}")
+	fs.BoolVar(&cfg.Timestamp, "timestamp", cfg.Timestamp, "Insert a \"Last updated\" line with the time and the source URL above the tables")
+	fs.StringVar(&cfg.Timezone, "timezone", cfg.Timezone, "Timezone of the timestamp and the title, such as Europe/Moscow, the local one by default")
+	fs.StringVar(&cfg.HashPath, "hash-file", cfg.HashPath, "Path to the file storing the hash of the tables last written to each document")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "Insert the tables one by one and log them, so that a rerun with -resume after a failure skips the inserted ones")
+	fs.StringVar(&cfg.ResumePath, "resume-file", cfg.ResumePath, "Path to the log of the tables inserted with -resume")
+	fs.BoolVar(&cfg.Since, "since", cfg.Since, "Skip the page if Confluence reports it unmodified since the last run, the page is synced when Confluence doesn't tell")
+	fs.StringVar(&cfg.ModifiedPath, "modified-file", cfg.ModifiedPath, "Path to the file storing the last modification time of each page for -since")
+	fs.BoolVar(&cfg.Force, "force", cfg.Force, "Rewrite the document even if the tables are unchanged since the last run")
+	fs.BoolVar(&cfg.Yes, "yes", cfg.Yes, "Clear a document that has content without asking, needed when stdin isn't a terminal")
+	fs.BoolVar(&cfg.Rename, "rename", cfg.Rename, "Also rename an existing document to the title, needs Drive access")
+	fs.StringVar(&cfg.DriveFolderId, "drive-folder-id", cfg.DriveFolderId, "Create new documents in this Drive folder, e.g. in a Shared Drive, needs full Drive access")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", cfg.ReadOnly, "Authorize with the documents.readonly scope, the document is checked but never written, use with -dry-run or -list-tables")
+	fs.StringVar(&cfg.CredentialsPath, "credentials", cfg.CredentialsPath, "Path to the OAuth client secret file")
+	fs.StringVar(&cfg.TokenPath, "token", cfg.TokenPath, "Path to the cached OAuth token")
+	fs.Var(&documentIdPathsFlag{cfg: cfg}, "document-id", "Path to the file storing the Google Docs document id of each URL, can be repeated to write the tables to several documents")
+	fs.IntVar(&cfg.FetchAttempts, "fetch-attempts", cfg.FetchAttempts, "Maximum number of attempts to fetch the Confluence page")
+	fs.DurationVar(&cfg.FetchMaxElapsed, "fetch-max-elapsed", cfg.FetchMaxElapsed, "Maximum total time spent retrying the Confluence fetch")
+	fs.DurationVar(&cfg.FetchTimeout, "fetch-timeout", cfg.FetchTimeout, "Timeout of a single Confluence request, including reading the body")
+	fs.StringVar(&cfg.ConfluenceUser, "confluence-user", cfg.ConfluenceUser, "Confluence user name for HTTP Basic Auth")
+	fs.StringVar(&cfg.ConfluencePass, "confluence-pass", cfg.ConfluencePass, "Confluence password for HTTP Basic Auth")
+	fs.StringVar(&cfg.ConfluenceToken, "confluence-token", cfg.ConfluenceToken, "Confluence personal access token, sent as a bearer token")
+	fs.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "User-Agent header of the Confluence requests")
+	fs.Var(headersFlag{&cfg.Headers}, "header", "Extra header of the Confluence requests, as KEY=VALUE, can be repeated")
+	fs.StringVar(&cfg.Proxy, "proxy", cfg.Proxy, "Proxy URL for the Confluence requests, by default HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used")
+	fs.StringVar(&cfg.CACert, "ca-cert", cfg.CACert, "PEM file with CA certificates to trust for Confluence, in addition to the system ones")
+	fs.BoolVar(&cfg.InsecureSkipVerify, "insecure-skip-verify", cfg.InsecureSkipVerify, "DANGEROUS: don't verify the Confluence TLS certificate")
+	fs.IntVar(&cfg.TableIndex, "table-index", cfg.TableIndex, "Only process the table with this 0-based index, -1 processes all tables")
+	fs.StringVar(&cfg.TableCaption, "table-caption", cfg.TableCaption, "Only process tables whose preceding heading or caption contains this text")
+	fs.BoolVar(&cfg.MergeContinuations, "merge-continuations", cfg.MergeContinuations, "Merge consecutive tables with the same header row into one, table indices count the merged tables")
+	fs.BoolVar(&cfg.DedupRows, "dedup-rows", cfg.DedupRows, "Drop every row that is identical to the row right above it")
+	fs.IntVar(&cfg.MaxRows, "max-rows", cfg.MaxRows, "Keep the header and at most this many data rows of every table, followed by a note row, 0 keeps all rows")
+	fs.StringVar(&cfg.Columns, "columns", cfg.Columns, "Only keep these columns, comma-separated header names from the first row or 0-based indices, in this order")
+	fs.StringVar(&cfg.RowFilter, "row-filter", cfg.RowFilter, "Only keep the header rows and the rows with a cell matching this regexp, e.g. (?i)active")
+	fs.BoolVar(&cfg.InvertRowFilter, "invert", cfg.InvertRowFilter, "Keep the rows that don't match -row-filter instead")
+	fs.BoolVar(&cfg.AllowEmpty, "allow-empty", cfg.AllowEmpty, "Go on when the page has no tables instead of failing, this clears the document")
+	fs.BoolVar(&cfg.ListTables, "list-tables", cfg.ListTables, "List the index, size, heading and first row of every table on the page and exit")
+	fs.StringVar(&cfg.Format, "format", cfg.Format, "Output format: gdoc writes to Google Docs, csv, json, md or txt write to -output")
+	fs.StringVar(&cfg.Output, "output", cfg.Output, "Output file of the json, md and txt formats or directory of the csv format, - for stdout or the current directory")
+	fs.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Print the scraped tables to stdout instead of writing them to Google Docs")
+	fs.StringVar(&cfg.CsvDir, "csv-dir", cfg.CsvDir, "Also write every table to table_<index>.csv in this directory")
+	fs.StringVar(&cfg.Markdown, "markdown", cfg.Markdown, "Also write the tables as GitHub-flavored Markdown to this file, - for stdout")
+	fs.StringVar(&cfg.JsonOut, "json-out", cfg.JsonOut, "Also write the tables as JSON to this file, - for stdout")
+	fs.StringVar(&cfg.TxtOut, "txt-out", cfg.TxtOut, "Also write the tables as aligned plain-text columns to this file, - for stdout")
+	fs.StringVar(&cfg.DiffPath, "diff-file", cfg.DiffPath, "Print the cells changed since the last run, whose tables are kept in this file, before writing")
+	fs.BoolVar(&cfg.DiffJson, "diff-json", cfg.DiffJson, "Print the -diff-file diff as JSON instead of text")
+	fs.BoolVar(&cfg.Check, "check", cfg.Check, "Check the credentials, the token and access to the document, print the outcome of each step and exit")
+	fs.BoolVar(&cfg.ClearOnly, "clear-only", cfg.ClearOnly, "Clear the document, asking first unless -yes is given, and exit without scraping anything")
+	fs.BoolVar(&cfg.StatsJson, "stats-json", cfg.StatsJson, "Print the summary of the run as a JSON object instead of key=value pairs")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFile, "log-file", cfg.LogFile, "Append the logs to this file instead of writing them to stderr")
+	fs.StringVar(&cfg.AuthMode, "auth-mode", cfg.AuthMode, "Google auth mode: auto, oauth or service-account, auto detects it from the credentials file")
+	fs.StringVar(&cfg.ServiceAccountSubject, "service-account-subject", cfg.ServiceAccountSubject, "User to impersonate with a domain-wide delegated service account")
+	fs.Float64Var(&cfg.DocsQPS, "docs-qps", cfg.DocsQPS, "Maximum Google Docs API requests per second, 0 disables the limit")
+	fs.BoolVar(&cfg.Trace, "trace", cfg.Trace, "Log every Google API request and response with its JSON body to the log, without the credentials")
+	fs.StringVar(&cfg.TableHeading, "table-heading", cfg.TableHeading, "Heading inserted above every table, e.g. \"Table {n}\", empty inserts none")
+	fs.BoolVar(&cfg.PageHeadings, "page-headings", cfg.PageHeadings, "Insert the heading preceding each table on the page as a section heading above it")
+	fs.BoolVar(&cfg.Toc, "toc", cfg.Toc, "Insert a table of contents linking to the headings at the beginning of the document")
+	fs.StringVar(&cfg.Selector, "selector", cfg.Selector, "CSS selector of the tables to scrape, e.g. table for themes without the confluenceTable class")
+	fs.StringVar(&cfg.ContainerSelector, "container-selector", cfg.ContainerSelector, "Only search for tables inside the elements matching this CSS selector, e.g. #main-content")
+	fs.BoolVar(&cfg.PreserveFormatting, "preserve-formatting", cfg.PreserveFormatting, "Carry bold and italic text of the cells into the document")
+	fs.BoolVar(&cfg.PreserveAlignment, "preserve-alignment", cfg.PreserveAlignment, "Align the text of the cells to the left, center or right like on the page")
+	fs.BoolVar(&cfg.Append, "append", cfg.Append, "Append the tables after the existing document content instead of clearing the document")
+	fs.StringVar(&cfg.Placeholder, "placeholder", cfg.Placeholder, "Replace the occurrences of this text in the document with the tables, e.g. {{TABLE}}, instead of clearing it")
+	fs.Var(replacementsFlag{&cfg.Replacements}, "replace", "Replace TOKEN in the document with a cell instead of inserting the tables, as TOKEN=TABLE:ROW:COL, where ROW is a first cell or a row index and COL a header or a column index, can be repeated")
+	fs.StringVar(&cfg.ColWidths, "col-widths", cfg.ColWidths, "Column widths in points, e.g. 100,200,150, or equal to distribute the width evenly")
+	fs.BoolVar(&cfg.BoldHeader, "bold-header", cfg.BoldHeader, "Bold the first row of every table, rows of th cells are bold anyway")
+	fs.BoolVar(&cfg.Zebra, "zebra", cfg.Zebra, "Color the background of every other table row")
+	fs.StringVar(&cfg.StripeColor, "stripe-color", cfg.StripeColor, "Background color of the striped rows, #rrggbb")
+	fs.BoolVar(&cfg.BorderNone, "border-none", cfg.BorderNone, "Hide the borders of the table cells")
+	fs.StringVar(&cfg.BorderColor, "border-color", cfg.BorderColor, "Color of the cell borders, #rrggbb, black if only the width or style is set")
+	fs.Float64Var(&cfg.BorderWidth, "border-width", cfg.BorderWidth, "Width of the cell borders in points, 1 if only the color or style is set")
+	fs.StringVar(&cfg.BorderStyle, "border-style", cfg.BorderStyle, "Style of the cell borders: solid, dot or dash")
+	fs.BoolVar(&cfg.StrictColumns, "strict-columns", cfg.StrictColumns, "Reject tables whose rows have different numbers of cells instead of padding the short rows")
+	fs.BoolVar(&cfg.NormalizeCells, "normalize-cells", cfg.NormalizeCells, "Trim cells and collapse whitespace, -normalize-cells=false keeps the raw text")
+	fs.BoolVar(&cfg.KeepLineBreaks, "keep-line-breaks", cfg.KeepLineBreaks, "Keep line breaks such as <br> when normalizing cells")
+	fs.BoolVar(&cfg.BrAsNewline, "br-as-newline", cfg.BrAsNewline, "Turn every <br> and paragraph boundary in cells into a single newline, so the lines become separate paragraphs in Google Docs")
+	fs.StringVar(&cfg.Lists, "lists", cfg.Lists, "Lists in cells: text keeps html2text's rendering, lines puts every item on its own line, bullets also makes them Google Docs bullet lists")
+	fs.IntVar(&cfg.MaxCellChars, "max-cell-chars", cfg.MaxCellChars, "Truncate cells longer than this many characters with an ellipsis, 0 disables truncation")
+	fs.BoolVar(&cfg.Images, "images", cfg.Images, "Keep the alt text of the images in the cells and insert the images into Google Docs, they have to be downloadable without the Confluence credentials")
+	return fs
+}
+
+func parseFlags(args []string) (*Config, error) {
+	cfg := defaultConfig()
+	configPath := ""
+	fs := newFlagSet(cfg, &configPath)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// The version is printed even when the rest of the flags or the config file are invalid.
+	if cfg.Version {
+		return cfg, nil
+	}
+
+	if configPath != "" {
+		fileCfg, err := loadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse the flags again with the file values as defaults, so explicitly passed flags win.
+		cfg = fileCfg
+		fs = newFlagSet(cfg, &configPath)
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "url" {
+			cfg.urlSet = true
+		}
+	})
+	cfg.applyEnv(fs)
+
+	if err := cfg.resolvePageURLs(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Builds the URL of page_id and normalizes the pageId of the URLs, see scrape.NormalizePageURL.
+func (cfg *Config) resolvePageURLs() error {
+	var err error
+	if cfg.PageId != "" {
+		if cfg.urlSet || len(cfg.Jobs) > 0 || cfg.HtmlFile != "" {
+			return fmt.Errorf("page_id names the page, so it can't be used with url, jobs or html_file")
+		}
+		cfg.URL, err = scrape.PageURL(cfg.ConfluenceBase, cfg.PageId)
+		if err != nil {
+			return fmt.Errorf("Invalid page_id: %w", err)
+		}
+	}
+
+	cfg.URL, err = scrape.NormalizePageURL(cfg.URL)
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Jobs {
+		cfg.Jobs[i].URL, err = scrape.NormalizePageURL(cfg.Jobs[i].URL)
+		if err != nil {
+			return fmt.Errorf("Job #%v: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// The level is checked by validate, so an invalid value can't get here.
+func (cfg *Config) logLevel() slog.Level {
+	level := slog.LevelInfo
+	level.UnmarshalText([]byte(cfg.LogLevel))
+	return level
+}
+
+func (cfg *Config) authOptions() gdocs.AuthOptions {
+	return gdocs.AuthOptions{
+		CredentialsPath: cfg.CredentialsPath,
+		TokenPath:       cfg.TokenPath,
+		Credentials:     []byte(cfg.Credentials),
+		Token:           []byte(cfg.Token),
+		Mode:            cfg.AuthMode,
+		Subject:         cfg.ServiceAccountSubject,
+		QPS:             cfg.DocsQPS,
+		DriveAccess:     cfg.Rename,
+		FullDriveAccess: cfg.DriveFolderId != "",
+		ReadOnly:        cfg.ReadOnly,
+		Trace:           cfg.Trace,
+	}
+}
+
+func (cfg *Config) clientOptions() scrape.ClientOptions {
+	return scrape.ClientOptions{
+		Timeout: cfg.FetchTimeout,
+		Proxy:   cfg.Proxy,
+
+		CACert:             cfg.CACert,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+}
+
+func (cfg *Config) scrapeOptions(job Job) scrape.Options {
+	return scrape.Options{
+		URL:        job.URL,
+		HtmlFile:   cfg.HtmlFile,
+		SaveHtml:   cfg.SaveHtml,
+		Attempts:   cfg.FetchAttempts,
+		MaxElapsed: cfg.FetchMaxElapsed,
+		User:       cfg.ConfluenceUser,
+		Pass:       cfg.ConfluencePass,
+		Token:      cfg.ConfluenceToken,
+		UserAgent:  cfg.UserAgent,
+		Headers:    cfg.Headers,
+		Parse: scrape.ParseOptions{
+			Selector:           cfg.Selector,
+			Container:          cfg.ContainerSelector,
+			PreserveFormatting: cfg.PreserveFormatting,
+			PreserveAlignment:  cfg.PreserveAlignment,
+			NormalizeCells:     cfg.NormalizeCells,
+			KeepLineBreaks:     cfg.KeepLineBreaks,
+			BrAsNewline:        cfg.BrAsNewline,
+			Lists:              cfg.Lists,
+			Images:             cfg.Images,
+		},
+	}
+}
+
+// The timezone of the config, the timezone is checked by validate.
+func (cfg *Config) location() *time.Location {
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+const EQUAL_COLUMN_WIDTHS = "equal"
+
+func (cfg *Config) columns() []string {
+	if strings.TrimSpace(cfg.Columns) == "" {
+		return nil
+	}
+	return strings.Split(cfg.Columns, ",")
+}
+
+func parseColumnWidths(s string) ([]float64, error) {
+	if s == "" || s == EQUAL_COLUMN_WIDTHS {
+		return nil, nil
+	}
+
+	widths := []float64{}
+	for _, field := range strings.Split(s, ",") {
+		width, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("Invalid col_widths %q, expected positive widths in points separated by commas or %q", s, EQUAL_COLUMN_WIDTHS)
+		}
+		widths = append(widths, width)
+	}
+	return widths, nil
+}
+
+// The layout shared by all the tables, syncer sets the fields that differ between them.
+// The config is validated before use, so the column widths and the stripe color always parse here.
+func (cfg *Config) insertOptions() gdocs.InsertOptions {
+	widths, _ := parseColumnWidths(cfg.ColWidths)
+	var stripeColor *docs.RgbColor
+	if cfg.Zebra {
+		stripeColor, _ = gdocs.ParseHexColor(cfg.StripeColor)
+	}
+
+	// The options are checked by validate.
+	borders, _ := cfg.borders()
+
+	return gdocs.InsertOptions{
+		Heading:           cfg.TableHeading,
+		ColumnWidths:      widths,
+		EqualColumnWidths: cfg.ColWidths == EQUAL_COLUMN_WIDTHS,
+		StrictColumns:     cfg.StrictColumns,
+		StripeColor:       stripeColor,
+		BoldHeader:        cfg.BoldHeader,
+		Borders:           borders,
+	}
+}
+
+// The options of the syncer writing the tables of job, sharing client with the other jobs of the page.
+func (cfg *Config) syncOptions(job Job, client *http.Client, logger *slog.Logger) syncer.Options {
+	opts := syncer.Options{
+		URL:            job.URL,
+		DocumentId:     job.DocumentId,
+		DocumentIdPath: job.DocumentIdPath,
+		Title:          cfg.DocumentTitle,
+		Location:       cfg.location(),
+		Rename:         cfg.Rename,
+		DriveFolderId:  cfg.DriveFolderId,
+		Append:         cfg.Append,
+		Placeholder:    cfg.Placeholder,
+		AllowEmpty:     cfg.AllowEmpty,
+		PageHeadings:   cfg.PageHeadings,
+		Timestamp:      cfg.Timestamp,
+		Toc:            cfg.Toc,
+		HashPath:       cfg.HashPath,
+		Force:          cfg.Force,
+		Resume:         cfg.Resume,
+		ResumePath:     cfg.ResumePath,
+		Scrape:         cfg.scrapeOptions(job),
+		Crawl:          scrape.CrawlOptions{Depth: cfg.CrawlDepth, Selector: cfg.CrawlSelector},
+		Client:         cfg.clientOptions(),
+		HTTPClient:     client,
+		Auth:           cfg.authOptions(),
+		Insert:         cfg.insertOptions(),
+		Logger:         logger,
+	}
+	if !cfg.Yes {
+		opts.Confirm = confirmClear
+	}
+	return opts
+}
+
+// Returns the borders of the table cells, nil when none of the border options is set.
+func (cfg *Config) borders() (*gdocs.Borders, error) {
+	if !cfg.BorderNone && cfg.BorderColor == "" && cfg.BorderWidth == 0 && cfg.BorderStyle == "" {
+		return nil, nil
+	}
+	if cfg.BorderNone && (cfg.BorderColor != "" || cfg.BorderWidth != 0 || cfg.BorderStyle != "") {
+		return nil, fmt.Errorf("border_none hides the borders, so it can't be used with border_color, border_width or border_style")
+	}
+
+	borders := &gdocs.Borders{None: cfg.BorderNone, Width: cfg.BorderWidth}
+	if cfg.BorderColor != "" {
+		color, err := gdocs.ParseHexColor(cfg.BorderColor)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid border_color: %v", err)
+		}
+		borders.Color = color
+	}
+	if cfg.BorderWidth < 0 {
+		return nil, fmt.Errorf("border_width can't be negative, got %v", cfg.BorderWidth)
+	}
+	switch style := strings.ToUpper(cfg.BorderStyle); style {
+	case "", gdocs.BORDER_SOLID, gdocs.BORDER_DOT, gdocs.BORDER_DASH:
+		borders.Style = style
+	default:
+		return nil, fmt.Errorf("Invalid border_style %q, expected solid, dot or dash", cfg.BorderStyle)
+	}
+	return borders, nil
+}
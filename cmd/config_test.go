@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes a config file to a temporary directory and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFlagsLayering(t *testing.T) {
+	path := writeConfig(t, "url: https://confluence.example.com/file\ndocument_title: From the file\nfetch_attempts: 2\n")
+	tests := []struct {
+		name     string
+		args     []string
+		url      string
+		title    string
+		attempts int
+	}{
+		{"defaults", []string{}, CONFLUENCE_URL, DOCUMENT_TITLE, FETCH_ATTEMPTS},
+		{"flags", []string{"-title", "From the flags", "-fetch-attempts", "3"}, CONFLUENCE_URL, "From the flags", 3},
+		{"file", []string{"-config", path}, "https://confluence.example.com/file", "From the file", 2},
+		// Only the passed flags override the file, in any order.
+		{"flags over file", []string{"-fetch-attempts", "4", "-config", path}, "https://confluence.example.com/file", "From the file", 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := parseFlags(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.URL != test.url || cfg.DocumentTitle != test.title || cfg.FetchAttempts != test.attempts {
+				t.Errorf("config = %v %q %v, want %v %q %v", cfg.URL, cfg.DocumentTitle, cfg.FetchAttempts, test.url, test.title, test.attempts)
+			}
+			// The keys the file doesn't set keep their defaults.
+			if cfg.FetchTimeout != FETCH_TIMEOUT || cfg.TokenPath != TOKEN_PATH {
+				t.Errorf("config = %v %v, want the default timeout and token path", cfg.FetchTimeout, cfg.TokenPath)
+			}
+		})
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		args    []string
+		err     string
+	}{
+		{"missing file", "", []string{"-config", "missing.yaml"}, "Unable to read config file"},
+		{"broken file", "url: [", nil, "Unable to parse config file"},
+		{"wrong type", "fetch_attempts: many\n", nil, "Unable to parse config file"},
+		{"invalid file value", "fetch_attempts: 0\n", nil, "fetch_attempts must be at least 1"},
+		// A flag fixes an invalid value of the file, as validation runs on the result.
+		{"fixed by a flag", "fetch_attempts: 0\n", []string{"-fetch-attempts", "1"}, ""},
+		{"unknown flag", "", []string{"-no-such-flag"}, "flag provided but not defined"},
+		{"html file and url in the file", "url: https://confluence.example.com/file\n", []string{"-html-file", "page.html"}, "url and html_file are mutually exclusive"},
+		{"page id and url in the file", "url: https://confluence.example.com/file\n", []string{"-page-id", "123"}, "page_id names the page"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.content != "" {
+				args = append([]string{"-config", writeConfig(t, test.content)}, args...)
+			}
+			_, err := parseFlags(args)
+			if test.err == "" && err != nil {
+				t.Fatalf("parseFlags() = %v, want no error", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("parseFlags() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+// A -url naming the default page is still a given URL.
+func TestParseFlagsDefaultURL(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-html-file", "page.html"}, ""},
+		{[]string{"-url", CONFLUENCE_URL, "-html-file", "page.html"}, "url and html_file are mutually exclusive"},
+		{[]string{"-page-id", "123"}, ""},
+		{[]string{"-url", CONFLUENCE_URL, "-page-id", "123"}, "page_id names the page"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, err := parseFlags(test.args)
+			if test.err == "" && err != nil {
+				t.Fatalf("parseFlags() = %v, want no error", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("parseFlags() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+// A config file naming a page can be pointed back at the default one.
+func TestParseFlagsURLOverride(t *testing.T) {
+	path := writeConfig(t, "url: https://confluence.example.com/file\n")
+	cfg, err := parseFlags([]string{"-config", path, "-url", CONFLUENCE_URL})
+	if err != nil || cfg.URL != CONFLUENCE_URL {
+		t.Fatalf("parseFlags() = %v, want the URL of the flag", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		err    string
+	}{
+		{"defaults", func(cfg *Config) {}, ""},
+		{"missing values", func(cfg *Config) { cfg.URL, cfg.TokenPath = "", "" }, "Missing required config values: url, token_path"},
+		{"title template", func(cfg *Config) { cfg.DocumentTitle = "{{.Date" }, "Invalid document_title template"},
+		{"timezone", func(cfg *Config) { cfg.Timezone = "Mars/Olympus" }, "Invalid timezone"},
+		{"fetch attempts", func(cfg *Config) { cfg.FetchAttempts = 0 }, "fetch_attempts must be at least 1"},
+		{"fetch max elapsed", func(cfg *Config) { cfg.FetchMaxElapsed = 0 }, "fetch_max_elapsed must be positive"},
+		{"negative fetch max elapsed", func(cfg *Config) { cfg.FetchMaxElapsed = -time.Minute }, "fetch_max_elapsed must be positive"},
+		{"fetch timeout", func(cfg *Config) { cfg.FetchTimeout = -time.Second }, "fetch_timeout must be positive"},
+		{"html file and url", func(cfg *Config) { cfg.HtmlFile, cfg.urlSet = "page.html", true }, "url and html_file are mutually exclusive"},
+		{"html file", func(cfg *Config) { cfg.HtmlFile = "page.html" }, ""},
+		{"proxy", func(cfg *Config) { cfg.Proxy = "proxy:3128" }, "Invalid proxy"},
+		{"token and user", func(cfg *Config) { cfg.ConfluenceToken, cfg.ConfluenceUser = "pat", "ann" }, "mutually exclusive"},
+		{"auth mode", func(cfg *Config) { cfg.AuthMode = "password" }, "Invalid auth_mode"},
+		{"format", func(cfg *Config) { cfg.Format = "xlsx" }, "Invalid format"},
+		{"read only", func(cfg *Config) { cfg.ReadOnly = true }, "read_only can't write the document"},
+		{"read only dry run", func(cfg *Config) { cfg.ReadOnly, cfg.DryRun = true, true }, ""},
+		{"read only list tables", func(cfg *Config) { cfg.ReadOnly, cfg.ListTables = true, true }, ""},
+		{"read only check", func(cfg *Config) { cfg.ReadOnly, cfg.Check = true, true }, ""},
+		// The other formats don't write the document.
+		{"read only csv", func(cfg *Config) { cfg.ReadOnly, cfg.Format = true, FORMAT_CSV }, ""},
+		{"read only rename", func(cfg *Config) { cfg.ReadOnly, cfg.Rename = true, true }, "read_only and rename are mutually exclusive"},
+		{"append and placeholder", func(cfg *Config) { cfg.Append, cfg.Placeholder = true, "{{tables}}" }, "append and placeholder are mutually exclusive"},
+		{"replacement without token", func(cfg *Config) { cfg.Replacements = []Replacement{{Row: "Ann", Col: "Role"}} }, "Replacement #0 needs a token"},
+		{
+			"token replaced twice",
+			func(cfg *Config) { cfg.Replacements = []Replacement{{Token: "{{a}}"}, {Token: "{{a}}", Table: 1}} },
+			"The token {{a}} is replaced twice",
+		},
+		{"row filter", func(cfg *Config) { cfg.RowFilter = "(" }, "Invalid row_filter"},
+		{"lists", func(cfg *Config) { cfg.Lists = "numbers" }, "Invalid lists"},
+		{"max rows", func(cfg *Config) { cfg.MaxRows = -1 }, "max_rows must not be negative"},
+		{"selector", func(cfg *Config) { cfg.Selector = "table[" }, "Invalid selector"},
+		{"stripe color", func(cfg *Config) { cfg.Zebra, cfg.StripeColor = true, "#xyz" }, "Invalid stripe_color"},
+		// The color is only parsed when the stripes are on.
+		{"stripe color without zebra", func(cfg *Config) { cfg.StripeColor = "#xyz" }, ""},
+		{"log level", func(cfg *Config) { cfg.LogLevel = "loud" }, "Invalid log_level"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			test.modify(cfg)
+			err := cfg.validate()
+			if test.err == "" && err != nil {
+				t.Fatalf("validate() = %v, want no error", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("validate() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestHeadersFlag(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   map[string]string
+		err    string
+	}{
+		{[]string{"X-Team=data"}, map[string]string{"X-Team": "data"}, ""},
+		{[]string{" X-Team = data ", "Accept-Language=ru"}, map[string]string{"X-Team": "data", "Accept-Language": "ru"}, ""},
+		{[]string{"X-Team=data", "X-Team=ops"}, map[string]string{"X-Team": "ops"}, ""},
+		{[]string{"Cookie=a=b"}, map[string]string{"Cookie": "a=b"}, ""},
+		{[]string{"X-Empty="}, map[string]string{"X-Empty": ""}, ""},
+		{[]string{"X-Team"}, nil, "Expected KEY=VALUE"},
+		{[]string{" =data"}, nil, "Expected KEY=VALUE"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.values, " "), func(t *testing.T) {
+			var headers map[string]string
+			f := headersFlag{&headers}
+			var err error
+			for _, value := range test.values {
+				if err = f.Set(value); err != nil {
+					break
+				}
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Set() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(headers, test.want) {
+				t.Errorf("Set() = %v, %v, want %v", headers, err, test.want)
+			}
+		})
+	}
+}
+
+func TestHeadersFlagString(t *testing.T) {
+	headers := map[string]string{"X-Team": "data", "Accept-Language": "ru"}
+	if s := (headersFlag{&headers}).String(); s != "Accept-Language=ru X-Team=data" {
+		t.Errorf("String() = %q, want the sorted pairs", s)
+	}
+	if s := (headersFlag{}).String(); s != "" {
+		t.Errorf("String() of the zero flag = %q, want empty", s)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Reports whether stdin is a terminal, so that there is someone to answer a prompt.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Guards the prompts, so that the questions of concurrent jobs don't interleave.
+var promptMu sync.Mutex
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Asks a yes or no question on stderr and reads the answer from stdin, anything but y or yes is a no.
+func confirm(question string) (bool, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "%v [y/N]: ", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil && answer == "" {
+		return false, fmt.Errorf("Unable to read the answer: %v", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
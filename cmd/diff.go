@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hflabstesttask/export"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Reads the tables of the last run by page URL, like the document ids, each as the JSON of export.WriteTablesJSON.
+// A missing file means no tables.
+func loadLastTables(path string) (map[string]json.RawMessage, error) {
+	last := map[string]json.RawMessage{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read diff file: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &last); err != nil {
+		return nil, fmt.Errorf("Unable to parse diff file %v: %v", path, err)
+	}
+	return last, nil
+}
+
+// Guards the read-modify-write of the diff file by concurrent jobs.
+var lastTablesMu sync.Mutex
+
+func saveLastTables(path string, pageURL string, tables []scrape.Table) error {
+	lastTablesMu.Lock()
+	defer lastTablesMu.Unlock()
+
+	last, err := loadLastTables(path)
+	if err != nil {
+		return err
+	}
+
+	b := bytes.Buffer{}
+	if err := export.WriteTablesJSON(tables, &b); err != nil {
+		return err
+	}
+	last[pageURL] = b.Bytes()
+
+	out, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0666); err != nil {
+		return fmt.Errorf("Unable to write diff file: %v", err)
+	}
+	return nil
+}
+
+// Prints the diff of tables against the tables stored for the job by the last run. Nothing is printed
+// on the first run of a job, as there is nothing to compare with.
+func (cfg *Config) printDiff(job Job, tables []scrape.Table, logger *slog.Logger) error {
+	lastTablesMu.Lock()
+	last, err := loadLastTables(cfg.DiffPath)
+	lastTablesMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	raw, ok := last[job.URL]
+	if !ok {
+		logger.Info("No tables of a previous run to diff against", "diff_file", cfg.DiffPath)
+		return nil
+	}
+	old, err := export.ReadTablesJSON(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("Unable to parse the last tables in diff file %v: %v", cfg.DiffPath, err)
+	}
+
+	diff := scrape.DiffTables(old, tables)
+	b := cfg.stdoutBuffer(job)
+	if cfg.DiffJson {
+		err = export.WriteDiffJSON(diff, b)
+	} else {
+		err = export.WriteDiffText(diff, b)
+	}
+	if err != nil {
+		return err
+	}
+	return writeStdout(b.Bytes())
+}
+
+// Failing to save the tables only makes the next diff cover more changes, so it isn't an error.
+func (cfg *Config) saveJobTables(job Job, tables []scrape.Table, logger *slog.Logger) {
+	if err := saveLastTables(cfg.DiffPath, job.URL, tables); err != nil {
+		logger.Warn("Unable to save the tables for the next diff", "err", err)
+	}
+}
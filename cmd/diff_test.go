@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"hflabstesttask/export"
+	"hflabstesttask/scrape"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLastTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last_tables.json")
+
+	last, err := loadLastTables(path)
+	if err != nil || len(last) != 0 {
+		t.Fatalf("loadLastTables() of a missing file = %v, %v, want no tables", last, err)
+	}
+
+	users := []scrape.Table{{Heading: "Users", Contents: []scrape.Row{scrape.NewRow("Ann", "30")}}}
+	regions := []scrape.Table{{Contents: []scrape.Row{scrape.NewRow("Moscow")}}}
+	if err := saveLastTables(path, "https://a", users); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveLastTables(path, "https://b", regions); err != nil {
+		t.Fatal(err)
+	}
+	// Saving a page again replaces only its tables.
+	users[0].Contents[0] = scrape.NewRow("Ann", "31")
+	if err := saveLastTables(path, "https://a", users); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err = loadLastTables(path)
+	if err != nil || len(last) != 2 {
+		t.Fatalf("loadLastTables() = %v, %v, want the tables of 2 pages", last, err)
+	}
+	for url, want := range map[string][]scrape.Table{"https://a": users, "https://b": regions} {
+		got, err := export.ReadTablesJSON(bytes.NewReader(last[url]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := scrape.DiffTables(want, got); !diff.Empty() {
+			t.Errorf("tables of %v differ from the saved ones: %+v", url, diff)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadLastTables(path); err == nil {
+		t.Errorf("loadLastTables() of a broken file succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/export"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"hflabstesttask/syncer"
+)
+
+const FORMAT_GDOC = "gdoc"
+const FORMAT_CSV = "csv"
+const FORMAT_JSON = "json"
+const FORMAT_MARKDOWN = "md"
+const FORMAT_TEXT = "txt"
+
+// Writes the tables of a job to its Google Docs document with syncer.Write.
+// A new document is only created on Write, when its title can include the number of tables.
+type docsWriter struct {
+	ctx    context.Context
+	cfg    *Config
+	srv    *docs.Service
+	syncer *syncer.Syncer
+	// The stored document of the job, nil if there is none yet.
+	doc *docs.Document
+}
+
+// Picks the backend of -format, the format is checked by validate.
+func (cfg *Config) newWriter(ctx context.Context, srv *docs.Service, s *syncer.Syncer, doc *docs.Document) export.Writer {
+	switch cfg.Format {
+	case FORMAT_CSV:
+		dir := cfg.Output
+		if dir == "-" {
+			dir = "."
+		}
+		return export.CSVWriter{Dir: dir}
+	case FORMAT_JSON:
+		return export.JSONWriter{Path: cfg.Output}
+	case FORMAT_MARKDOWN:
+		return export.MarkdownWriter{Path: cfg.Output}
+	case FORMAT_TEXT:
+		return export.TextWriter{Path: cfg.Output}
+	default:
+		if len(cfg.Replacements) > 0 {
+			return &replaceWriter{ctx: ctx, cfg: cfg, srv: srv, doc: doc, logger: s.Logger()}
+		}
+		return &docsWriter{ctx: ctx, cfg: cfg, srv: srv, syncer: s, doc: doc}
+	}
+}
+
+func (w *docsWriter) Write(tables []scrape.Table) error {
+	if w.cfg.ReadOnly {
+		return fmt.Errorf("%w: refusing to create, clear or insert into the document, drop -read-only or add -dry-run", gdocs.ErrReadOnly)
+	}
+
+	result, err := w.syncer.Write(w.ctx, w.srv, w.doc, tables)
+	if err != nil {
+		return err
+	}
+	// The syncer logs these in terms of its options, the flags are up to the command.
+	if result.Unchanged {
+		w.syncer.Logger().Info("Skipped the unchanged tables", "hint", "-force rewrites them")
+	} else if w.cfg.Toc && result.TocEntries == 0 {
+		w.syncer.Logger().Warn("The table of contents is empty", "hint", "add -table-heading or -page-headings")
+	}
+	return nil
+}
+
+// Asks before clearing a document that has content, passed as syncer.Options.Confirm unless -yes is given.
+// Without a terminal to ask on, -yes is required.
+func confirmClear(doc *docs.Document, length int64) error {
+	if !isInteractive() {
+		return withExitCode(EXIT_USAGE, fmt.Errorf("Refusing to clear document %q (%v) with %v characters without a terminal to confirm, pass -yes", doc.Title, doc.DocumentId, length))
+	}
+
+	ok, err := confirm(fmt.Sprintf("Clear document %q (%v) with %v characters?", doc.Title, doc.DocumentId, length))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Clearing document %v was declined", doc.DocumentId)
+	}
+	return nil
+}
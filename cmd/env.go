@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"os"
+)
+
+// Environment variables for containerized runs, where mounting files is inconvenient.
+// Each one takes precedence over the file it replaces, but not over an explicitly passed flag.
+const ENV_DOCUMENT_ID = "HFLABS_DOCUMENT_ID"
+const ENV_CREDENTIALS = "HFLABS_CREDENTIALS"
+const ENV_TOKEN = "HFLABS_TOKEN"
+
+// Takes the document id, credentials and token from the environment, unless
+// the flag of the file they replace was passed to fs.
+func (cfg *Config) applyEnv(fs *flag.FlagSet) {
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+
+	if value := os.Getenv(ENV_DOCUMENT_ID); value != "" && !passed["document-id"] {
+		cfg.DocumentId = value
+	}
+	if value := os.Getenv(ENV_CREDENTIALS); value != "" && !passed["credentials"] {
+		cfg.Credentials = value
+	}
+	if value := os.Getenv(ENV_TOKEN); value != "" && !passed["token"] {
+		cfg.Token = value
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		documentId  string
+		credentials string
+		token       string
+	}{
+		{"unset", map[string]string{}, []string{}, "", "", ""},
+		{
+			"set",
+			map[string]string{ENV_DOCUMENT_ID: "doc", ENV_CREDENTIALS: `{"installed": {}}`, ENV_TOKEN: `{"access_token": "x"}`},
+			[]string{},
+			"doc", `{"installed": {}}`, `{"access_token": "x"}`,
+		},
+		{"empty", map[string]string{ENV_DOCUMENT_ID: "", ENV_TOKEN: ""}, []string{}, "", "", ""},
+		// An explicitly passed flag of the file a variable replaces wins over the variable.
+		{
+			"flags win",
+			map[string]string{ENV_DOCUMENT_ID: "doc", ENV_CREDENTIALS: "creds", ENV_TOKEN: "token"},
+			[]string{"-document-id", "ids.txt", "-token", "token.json"},
+			"", "creds", "",
+		},
+		// Other flags don't stop the variables.
+		{"other flags", map[string]string{ENV_TOKEN: "token"}, []string{"-fetch-attempts", "2"}, "", "", "token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{ENV_DOCUMENT_ID, ENV_CREDENTIALS, ENV_TOKEN} {
+				t.Setenv(name, test.env[name])
+			}
+
+			cfg := defaultConfig()
+			configPath := ""
+			fs := newFlagSet(cfg, &configPath)
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			cfg.applyEnv(fs)
+
+			if cfg.DocumentId != test.documentId || cfg.Credentials != test.credentials || cfg.Token != test.token {
+				t.Errorf("applyEnv() = %q %q %q, want %q %q %q", cfg.DocumentId, cfg.Credentials, cfg.Token, test.documentId, test.credentials, test.token)
+			}
+		})
+	}
+}
+
+// The variables also apply on top of a config file, which has no keys for them.
+func TestParseFlagsEnv(t *testing.T) {
+	t.Setenv(ENV_DOCUMENT_ID, "doc")
+	path := writeConfig(t, "document_title: From the file\n")
+
+	cfg, err := parseFlags([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DocumentId != "doc" || cfg.DocumentTitle != "From the file" {
+		t.Errorf("config = %q %q, want the document id of the variable and the title of the file", cfg.DocumentId, cfg.DocumentTitle)
+	}
+}
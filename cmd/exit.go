@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"hflabstesttask/syncer"
+)
+
+// Exit codes, so that scripts can tell what went wrong.
+const EXIT_FAILURE = 1
+const EXIT_USAGE = 2
+
+// Google authorization failed or the document is inaccessible.
+const EXIT_AUTH = 3
+
+// The Confluence page couldn't be fetched or had no matching tables.
+const EXIT_SCRAPE = 4
+
+// Clearing the document or inserting some of the tables failed.
+const EXIT_WRITE = 5
+const EXIT_CANCELLED = 130
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// Exit codes of the sentinel errors of the syncer, scrape and gdocs packages, for errors not wrapped by
+// withExitCode. The step of the syncer comes first, an insert that fails on a gone document is a write failure.
+var sentinelExitCodes = []struct {
+	err  error
+	code int
+}{
+	{syncer.ErrDocument, EXIT_AUTH},
+	{syncer.ErrScrape, EXIT_SCRAPE},
+	{syncer.ErrWrite, EXIT_WRITE},
+	{gdocs.ErrAuth, EXIT_AUTH},
+	{gdocs.ErrDocumentUnavailable, EXIT_AUTH},
+	{gdocs.ErrMalformedTable, EXIT_WRITE},
+	{gdocs.ErrTooLarge, EXIT_WRITE},
+	{gdocs.ErrReadOnly, EXIT_USAGE},
+	{scrape.ErrAccessDenied, EXIT_SCRAPE},
+	{scrape.ErrPageNotFound, EXIT_SCRAPE},
+	{scrape.ErrNoTables, EXIT_SCRAPE},
+}
+
+// Returns the exit code of err. Errors joined from several jobs with different codes get EXIT_FAILURE.
+func exitCode(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return EXIT_CANCELLED
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		code := 0
+		for _, err := range joined.Unwrap() {
+			if c := exitCode(err); code == 0 || code == c {
+				code = c
+			} else {
+				return EXIT_FAILURE
+			}
+		}
+		if code != 0 {
+			return code
+		}
+	}
+
+	exitErr := &exitError{}
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	for _, sentinel := range sentinelExitCodes {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.code
+		}
+	}
+	return EXIT_FAILURE
+}
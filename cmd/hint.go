@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"log/slog"
+)
+
+// Flags that fix the sentinel errors of the scrape and gdocs packages, which don't know the flags of the command.
+var sentinelHints = []struct {
+	err  error
+	hint string
+}{
+	{scrape.ErrNoTables, "check the selectors against the page saved with -save-html, -allow-empty clears the document anyway"},
+	{gdocs.ErrTooLarge, "cut the tables down with -max-rows, -columns, -row-filter or -max-cell-chars"},
+}
+
+// Returns the flag that may fix err, empty if there is none.
+func errorHint(err error) string {
+	certErr := &tls.CertificateVerificationError{}
+	if errors.As(err, &certErr) {
+		return "pass the CA with -ca-cert"
+	}
+	for _, sentinel := range sentinelHints {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.hint
+		}
+	}
+	return ""
+}
+
+// Logs err along with its hint.
+func logError(logger *slog.Logger, msg string, err error) {
+	args := []any{"err", err}
+	if hint := errorHint(err); hint != "" {
+		args = append(args, "hint", hint)
+	}
+	logger.Error(msg, args...)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"hflabstesttask/syncer"
+	"strings"
+	"testing"
+)
+
+func TestErrorHint(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		hint string
+	}{
+		{"no tables", fmt.Errorf("%w: %w found on the page", syncer.ErrScrape, scrape.ErrNoTables), "-allow-empty"},
+		{"too large", fmt.Errorf("Document %v: %w", "doc", gdocs.ErrTooLarge), "-max-rows"},
+		{"untrusted", fmt.Errorf("Get page: %w", &tls.CertificateVerificationError{}), "-ca-cert"},
+		{"other", fmt.Errorf("Failed to get tables"), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hint := errorHint(test.err)
+			if test.hint == "" && hint != "" || !strings.Contains(hint, test.hint) {
+				t.Errorf("errorHint(%v) = %q, want %q", test.err, hint, test.hint)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/export"
+	"hflabstesttask/scrape"
+	"hflabstesttask/syncer"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Confluence page and the file storing the id of the document its tables are written to.
+type Job struct {
+	URL            string `yaml:"url"`
+	DocumentIdPath string `yaml:"document_id_path"`
+	// Id of an existing document to use instead of the one stored in DocumentIdPath.
+	DocumentId string `yaml:"-"`
+}
+
+// Collects the repeated -job URL=DOCUMENT_ID_PATH flags.
+type jobsFlag struct {
+	jobs *[]Job
+}
+
+func (f jobsFlag) String() string {
+	if f.jobs == nil {
+		return ""
+	}
+
+	pairs := []string{}
+	for _, job := range *f.jobs {
+		pairs = append(pairs, job.URL+"="+job.DocumentIdPath)
+	}
+	return strings.Join(pairs, " ")
+}
+
+func (f jobsFlag) Set(value string) error {
+	// URLs can contain "=" in the query, document id paths usually don't.
+	i := strings.LastIndex(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("Expected URL=DOCUMENT_ID_PATH, got %q", value)
+	}
+
+	*f.jobs = append(*f.jobs, Job{URL: value[:i], DocumentIdPath: value[i+1:]})
+	return nil
+}
+
+// Collects the repeated -document-id flags, the first one replaces document_id_path and the rest
+// replace mirror_document_id_paths.
+type documentIdPathsFlag struct {
+	cfg    *Config
+	passed bool
+}
+
+func (f *documentIdPathsFlag) String() string {
+	if f == nil || f.cfg == nil {
+		return ""
+	}
+	return strings.Join(append([]string{f.cfg.DocumentIdPath}, f.cfg.MirrorDocumentIdPaths...), " ")
+}
+
+func (f *documentIdPathsFlag) Set(value string) error {
+	if !f.passed {
+		f.passed = true
+		f.cfg.DocumentIdPath = value
+		f.cfg.MirrorDocumentIdPaths = nil
+		return nil
+	}
+	f.cfg.MirrorDocumentIdPaths = append(f.cfg.MirrorDocumentIdPaths, value)
+	return nil
+}
+
+// Without jobs in the config, -url and -document-id make up the only job, and a job per mirror document.
+func (cfg *Config) jobs() []Job {
+	if len(cfg.Jobs) > 0 {
+		return cfg.Jobs
+	}
+
+	jobs := []Job{{URL: cfg.URL, DocumentIdPath: cfg.DocumentIdPath, DocumentId: cfg.DocumentId}}
+	for _, path := range cfg.MirrorDocumentIdPaths {
+		jobs = append(jobs, Job{URL: cfg.URL, DocumentIdPath: path})
+	}
+	return jobs
+}
+
+// Groups the jobs by URL in the order of their first job, so that a page written to several documents
+// is scraped once.
+func groupJobs(jobs []Job) [][]Job {
+	groups := [][]Job{}
+	index := map[string]int{}
+	for _, job := range jobs {
+		i, ok := index[job.URL]
+		if !ok {
+			i = len(groups)
+			index[job.URL] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], job)
+	}
+	return groups
+}
+
+// Names the document of the job in logs and errors.
+func (job Job) target() string {
+	if job.DocumentId != "" {
+		return job.DocumentId
+	}
+	return job.DocumentIdPath
+}
+
+// Guards stdout, so that the tables printed by concurrent jobs don't interleave.
+var stdoutMu sync.Mutex
+
+func writeStdout(b []byte) error {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err := os.Stdout.Write(b)
+	return err
+}
+
+// Returns a buffer for the stdout output of the job, starting with the job's URL when there are several jobs.
+func (cfg *Config) stdoutBuffer(job Job) *bytes.Buffer {
+	b := &bytes.Buffer{}
+	if len(cfg.Jobs) > 0 {
+		fmt.Fprintf(b, "== %v ==\n", job.URL)
+	}
+	return b
+}
+
+// Scrapes the page of the jobs, which share the URL, once and writes its tables to the document of every job,
+// or to the output of -format. The stored documents are checked before scraping, so that a bad document id
+// or missing access fails fast. A document that fails doesn't stop the others, their errors are joined.
+func runJob(ctx context.Context, cfg *Config, srv *docs.Service, targets []Job, logger *slog.Logger) error {
+	job := targets[0]
+	client, err := scrape.NewClient(cfg.clientOptions())
+	if err != nil {
+		return err
+	}
+
+	// Checked first, as skipping the job is the point.
+	var modified time.Time
+	if cfg.Since {
+		var unchanged bool
+		if modified, unchanged = cfg.pageUnchanged(ctx, client, job, logger); unchanged {
+			return nil
+		}
+	}
+
+	targetLoggers := make([]*slog.Logger, len(targets))
+	syncers := make([]*syncer.Syncer, len(targets))
+	for i, target := range targets {
+		targetLoggers[i] = logger
+		if len(targets) > 1 {
+			targetLoggers[i] = logger.With("document", target.target())
+		}
+		syncers[i], err = syncer.New(cfg.syncOptions(target, client, targetLoggers[i]))
+		if err != nil {
+			return err
+		}
+	}
+
+	targetDocs := make([]*docs.Document, len(targets))
+	targetErrs := make([]error, len(targets))
+	failed := 0
+	if srv != nil {
+		for i := range targets {
+			targetDocs[i], targetErrs[i] = syncers[i].OpenDocument(ctx, srv)
+			if targetErrs[i] != nil {
+				if len(targets) > 1 {
+					logError(targetLoggers[i], "Failed to open document", targetErrs[i])
+				}
+				failed++
+			}
+		}
+	}
+	if failed == len(targets) {
+		return targetsError(targets, targetErrs)
+	}
+
+	tables, err := syncers[0].Scrape(ctx)
+	if err != nil {
+		return err
+	}
+	tablesFound.Add(int64(len(tables)))
+
+	if cfg.MergeContinuations {
+		tables = scrape.MergeContinuationTables(tables)
+		logger.Debug("Merged continuation tables", "count", len(tables))
+	}
+
+	if cfg.ListTables {
+		b := cfg.stdoutBuffer(job)
+		if err := export.ListTables(tables, b); err != nil {
+			return fmt.Errorf("Failed to list tables: %w", err)
+		}
+		return writeStdout(b.Bytes())
+	}
+
+	tables, err = scrape.SelectTables(tables, scrape.Filter{Index: cfg.TableIndex, Caption: cfg.TableCaption})
+	if err != nil {
+		return withExitCode(EXIT_SCRAPE, fmt.Errorf("Failed to select tables: %w", err))
+	}
+
+	if columns := cfg.columns(); len(columns) > 0 {
+		for i := range tables {
+			tables[i], err = scrape.SelectColumns(tables[i], columns)
+			if err != nil {
+				return withExitCode(EXIT_SCRAPE, fmt.Errorf("Failed to select columns of table #%v: %w", i, err))
+			}
+		}
+	}
+
+	// The filter is checked by validate.
+	if cfg.RowFilter != "" {
+		re := regexp.MustCompile(cfg.RowFilter)
+		for i := range tables {
+			tables[i] = scrape.FilterRows(tables[i], re, cfg.InvertRowFilter)
+		}
+	}
+
+	if cfg.DedupRows {
+		dropped := 0
+		for i := range tables {
+			rowCnt := len(tables[i].Contents)
+			tables[i] = scrape.DedupRows(tables[i])
+			dropped += rowCnt - len(tables[i].Contents)
+		}
+		logger.Debug("Dropped repeated rows", "rows", dropped)
+	}
+
+	if cfg.MaxRows > 0 {
+		for i := range tables {
+			var truncated bool
+			if tables[i], truncated = scrape.TruncateRows(tables[i], cfg.MaxRows); truncated {
+				logger.Warn("Truncated a long table", "table", i, "max_rows", cfg.MaxRows)
+			}
+		}
+	}
+
+	if truncated := scrape.TruncateCells(tables, cfg.MaxCellChars); truncated > 0 {
+		logger.Warn("Truncated long cells", "cells", truncated, "max_cell_chars", cfg.MaxCellChars)
+	}
+
+	if cfg.CsvDir != "" {
+		if err := (export.CSVWriter{Dir: cfg.CsvDir}).Write(tables); err != nil {
+			return fmt.Errorf("Failed to export tables to CSV: %w", err)
+		}
+	}
+
+	if cfg.Markdown != "" {
+		if err := (export.MarkdownWriter{Path: cfg.Markdown}).Write(tables); err != nil {
+			return fmt.Errorf("Failed to export tables to Markdown: %w", err)
+		}
+	}
+
+	if cfg.JsonOut != "" {
+		if err := (export.JSONWriter{Path: cfg.JsonOut}).Write(tables); err != nil {
+			return fmt.Errorf("Failed to export tables to JSON: %w", err)
+		}
+	}
+
+	if cfg.TxtOut != "" {
+		if err := (export.TextWriter{Path: cfg.TxtOut}).Write(tables); err != nil {
+			return fmt.Errorf("Failed to export tables to plain text: %w", err)
+		}
+	}
+
+	if cfg.DiffPath != "" {
+		if err := cfg.printDiff(job, tables, logger); err != nil {
+			return fmt.Errorf("Failed to diff tables: %w", err)
+		}
+	}
+
+	// Nothing is written on a dry run, so the tables aren't saved for the next diff either.
+	if cfg.DryRun {
+		b := cfg.stdoutBuffer(job)
+		if err := export.PrintTables(tables, b); err != nil {
+			return fmt.Errorf("Failed to print tables: %w", err)
+		}
+		return writeStdout(b.Bytes())
+	}
+
+	// Only Google Docs inserts the images, the other outputs keep their alt text.
+	if cfg.Images && cfg.Format == FORMAT_GDOC {
+		if dropped := scrape.CheckImages(ctx, client, tables); dropped > 0 {
+			logger.Warn("Kept the alt text of the images that can't be inserted", "images", dropped)
+		}
+	}
+
+	for i := range targets {
+		if targetErrs[i] != nil {
+			continue
+		}
+		targetErrs[i] = cfg.newWriter(ctx, srv, syncers[i], targetDocs[i]).Write(tables)
+		if len(targets) == 1 {
+			continue
+		}
+		if targetErrs[i] != nil {
+			logError(targetLoggers[i], "Failed to write document", targetErrs[i])
+		} else {
+			targetLoggers[i].Info("Wrote document")
+		}
+	}
+	if err := targetsError(targets, targetErrs); err != nil {
+		return err
+	}
+	if cfg.DiffPath != "" {
+		cfg.saveJobTables(job, tables, logger)
+	}
+	if !modified.IsZero() {
+		cfg.saveJobModified(job, modified, logger)
+	}
+	return nil
+}
+
+// Joins the errors of the documents, naming each document when there are several.
+func targetsError(targets []Job, errs []error) error {
+	if len(targets) == 1 {
+		return errs[0]
+	}
+
+	named := []error{}
+	for i, err := range errs {
+		if err != nil {
+			named = append(named, fmt.Errorf("Document %v: %w", targets[i].target(), err))
+		}
+	}
+	return errors.Join(named...)
+}
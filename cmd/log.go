@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Closes the log file, if there is one. It is called before exiting, as os.Exit skips deferred calls.
+var closeLog = func() {}
+
+// Sets the default logger to log at the level of the config to the log file, or to stderr without one.
+// The file is appended to, so that it can be rotated by truncating it, such as with logrotate's copytruncate.
+func (cfg *Config) setupLogging() error {
+	var out io.Writer = os.Stderr
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("Unable to open log file: %v", err)
+		}
+		closeLog = func() {
+			f.Sync()
+			f.Close()
+		}
+		out = f
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: cfg.logLevel()})))
+	return nil
+}
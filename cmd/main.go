@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Logs err and exits with its exit code.
+func fatal(msg string, err error) {
+	if errors.Is(err, context.Canceled) {
+		slog.Error("Cancelled", "err", err)
+	} else {
+		logError(slog.Default(), msg, err)
+	}
+	closeLog()
+	os.Exit(exitCode(err))
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(EXIT_USAGE)
+	}
+
+	if cfg.Version {
+		printVersion(os.Stdout)
+		return
+	}
+
+	if err := cfg.setupLogging(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(EXIT_USAGE)
+	}
+	defer closeLog()
+	start := time.Now()
+
+	// The first Ctrl-C cancels the requests in flight, a second one kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if cfg.Check {
+		if err := runCheck(ctx, cfg, os.Stdout); err != nil {
+			fatal("Check failed", err)
+		}
+		return
+	}
+
+	// One service is shared by all the jobs, so the OAuth prompt shows up once and the rate limit applies to all of them.
+	// With read-only access it is only used to check the document, so it is also needed when nothing is written.
+	var srv *docs.Service
+	if cfg.Format == FORMAT_GDOC && (cfg.ReadOnly || !cfg.DryRun && !cfg.ListTables) {
+		srv, err = gdocs.GetService(ctx, cfg.authOptions())
+		if err != nil {
+			fatal("Failed to get service", err)
+		}
+	}
+
+	if cfg.ClearOnly {
+		if err := runClearOnly(ctx, cfg, srv); err != nil {
+			fatal("Failed to clear documents", err)
+		}
+		return
+	}
+
+	groups := groupJobs(cfg.jobs())
+	errs := make([]error, len(groups))
+	g := errgroup.Group{}
+	g.SetLimit(cfg.Parallel)
+	for i, targets := range groups {
+		i, targets := i, targets
+		url := targets[0].URL
+		logger := slog.Default()
+		if len(groups) > 1 {
+			logger = logger.With("url", url)
+		}
+
+		g.Go(func() error {
+			err := runJob(ctx, cfg, srv, targets, logger)
+			if err != nil && len(groups) > 1 {
+				err = fmt.Errorf("Job %v: %w", url, err)
+			}
+			errs[i] = err
+			return nil
+		})
+	}
+	g.Wait()
+
+	// Stdout may hold the tables, so the summary goes to stderr.
+	if err := printStats(os.Stderr, collectStats(start), cfg.StatsJson); err != nil {
+		slog.Warn("Unable to print the summary of the run", "err", err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		fatal("Failed to run jobs", err)
+	}
+}
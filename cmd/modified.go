@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reads the last modification times of the pages synced by the previous runs by page URL, a missing file means none.
+func loadLastModified(path string) (map[string]time.Time, error) {
+	modified := map[string]time.Time{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return modified, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read modified file: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &modified); err != nil {
+		return nil, fmt.Errorf("Unable to parse modified file %v: %v", path, err)
+	}
+	return modified, nil
+}
+
+// Guards the read-modify-write of the modified file by concurrent jobs.
+var lastModifiedMu sync.Mutex
+
+func saveLastModified(path string, pageURL string, modified time.Time) error {
+	lastModifiedMu.Lock()
+	defer lastModifiedMu.Unlock()
+
+	last, err := loadLastModified(path)
+	if err != nil {
+		return err
+	}
+
+	last[pageURL] = modified
+	b, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0666); err != nil {
+		return fmt.Errorf("Unable to write modified file: %v", err)
+	}
+	return nil
+}
+
+// Asks Confluence when the page of the job was last modified, and reports whether that is when it was
+// modified at the last run, so the job can be skipped. The page is synced whenever that can't be told.
+// Also returns the modification time to save after the sync, zero if it is unknown.
+func (cfg *Config) pageUnchanged(ctx context.Context, client *http.Client, job Job, logger *slog.Logger) (time.Time, bool) {
+	modified, err := scrape.LastModified(ctx, client, cfg.scrapeOptions(job))
+	if err != nil {
+		logger.Info("Unable to tell whether the page has changed, syncing it", "err", err)
+		return time.Time{}, false
+	}
+	if cfg.Force {
+		return modified, false
+	}
+
+	lastModifiedMu.Lock()
+	last, err := loadLastModified(cfg.ModifiedPath)
+	lastModifiedMu.Unlock()
+	if err != nil {
+		logger.Warn("Unable to load the modification times of the previous runs", "err", err)
+		return modified, false
+	}
+
+	if stored, ok := last[job.URL]; ok && stored.Equal(modified) {
+		logger.Info("The page hasn't changed since the last run, use -force to sync it anyway", "last_modified", modified)
+		return modified, true
+	}
+	logger.Debug("The page has changed since the last run", "last_modified", modified)
+	return modified, false
+}
+
+// Failing to save the time only costs a sync of the unchanged page on the next run, so it isn't an error.
+func (cfg *Config) saveJobModified(job Job, modified time.Time, logger *slog.Logger) {
+	if err := saveLastModified(cfg.ModifiedPath, job.URL, modified); err != nil {
+		logger.Warn("Unable to save the modification time of the page", "err", err)
+	}
+}
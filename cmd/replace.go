@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// A token of a template document and the cell whose text replaces it, see scrape.LookupCell.
+type Replacement struct {
+	Token string `yaml:"token"`
+	// 0-based index of the table after the tables are selected.
+	Table int `yaml:"table"`
+	// Text of the first cell of the row or a 0-based row index.
+	Row string `yaml:"row"`
+	// Header name from the first row or a 0-based column index.
+	Col string `yaml:"col"`
+}
+
+// Collects the repeated -replace TOKEN=TABLE:ROW:COL flags.
+type replacementsFlag struct {
+	replacements *[]Replacement
+}
+
+func (f replacementsFlag) String() string {
+	if f.replacements == nil {
+		return ""
+	}
+
+	values := []string{}
+	for _, r := range *f.replacements {
+		values = append(values, fmt.Sprintf("%v=%v:%v:%v", r.Token, r.Table, r.Row, r.Col))
+	}
+	return strings.Join(values, " ")
+}
+
+func (f replacementsFlag) Set(value string) error {
+	token, ref, ok := strings.Cut(value, "=")
+	parts := strings.SplitN(ref, ":", 3)
+	if !ok || token == "" || len(parts) != 3 {
+		return fmt.Errorf("Expected TOKEN=TABLE:ROW:COL, got %q", value)
+	}
+
+	table, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("Invalid table index %q in %q", parts[0], value)
+	}
+
+	*f.replacements = append(*f.replacements, Replacement{Token: token, Table: table, Row: parts[1], Col: parts[2]})
+	return nil
+}
+
+// Fills the tokens of the job's template document with the cells of the tables, instead of inserting the tables.
+// The rest of the document is left as it is, and the replaced tokens are gone for the next run.
+type replaceWriter struct {
+	ctx context.Context
+	cfg *Config
+	srv *docs.Service
+	// The stored document of the job, nil if there is none yet.
+	doc    *docs.Document
+	logger *slog.Logger
+}
+
+func (w *replaceWriter) Write(tables []scrape.Table) error {
+	if w.cfg.ReadOnly {
+		return fmt.Errorf("%w: refusing to replace the tokens of the document, drop -read-only or add -dry-run", gdocs.ErrReadOnly)
+	}
+	if w.doc == nil {
+		return withExitCode(EXIT_USAGE, fmt.Errorf("Replacements need an existing document with the tokens, set its id in -document-id"))
+	}
+
+	values := map[string]string{}
+	for _, r := range w.cfg.Replacements {
+		value, err := scrape.LookupCell(tables, r.Table, r.Row, r.Col)
+		if err != nil {
+			return withExitCode(EXIT_SCRAPE, fmt.Errorf("Failed to look up the value of %v: %w", r.Token, err))
+		}
+		values[r.Token] = value
+	}
+
+	replaced, err := gdocs.ReplaceAllText(w.ctx, w.doc.DocumentId, w.srv, values)
+	if err != nil {
+		return withExitCode(EXIT_WRITE, fmt.Errorf("Failed to replace tokens: %w", err))
+	}
+	for _, r := range w.cfg.Replacements {
+		if replaced[r.Token] == 0 {
+			w.logger.Warn("The token isn't in the document, it may have been replaced by a previous run", "token", r.Token)
+		} else {
+			w.logger.Info("Replaced token", "token", r.Token, "occurrences", replaced[r.Token])
+		}
+	}
+	return nil
+}
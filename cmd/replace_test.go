@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplacementsFlagSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Replacement
+		err   string
+	}{
+		{"{{admin}}=0:Ann:Role", Replacement{Token: "{{admin}}", Table: 0, Row: "Ann", Col: "Role"}, ""},
+		{"{{code}}=2:1:0", Replacement{Token: "{{code}}", Table: 2, Row: "1", Col: "0"}, ""},
+		// Only the first = and the first two colons separate the parts.
+		{"a=b=1:x:y", Replacement{}, "Invalid table index \"b=1\""},
+		{"{{time}}=0:Start:10:00", Replacement{Token: "{{time}}", Table: 0, Row: "Start", Col: "10:00"}, ""},
+		{"{{admin}}=0::", Replacement{Token: "{{admin}}", Table: 0}, ""},
+		{"{{admin}}", Replacement{}, "Expected TOKEN=TABLE:ROW:COL"},
+		{"=0:Ann:Role", Replacement{}, "Expected TOKEN=TABLE:ROW:COL"},
+		{"{{admin}}=0:Ann", Replacement{}, "Expected TOKEN=TABLE:ROW:COL"},
+		{"{{admin}}=first:Ann:Role", Replacement{}, "Invalid table index \"first\""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			replacements := []Replacement{}
+			err := replacementsFlag{&replacements}.Set(test.value)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Set() = %v, want %q", err, test.err)
+				}
+				if len(replacements) != 0 {
+					t.Errorf("Set() added %v after an error", replacements)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(replacements, []Replacement{test.want}) {
+				t.Errorf("Set() = %+v, %v, want %+v", replacements, err, test.want)
+			}
+		})
+	}
+}
+
+func TestReplacementsFlagString(t *testing.T) {
+	replacements := []Replacement{}
+	f := replacementsFlag{&replacements}
+	if s := (replacementsFlag{}).String(); s != "" {
+		t.Errorf("String() of the zero flag = %q, want empty", s)
+	}
+
+	for _, value := range []string{"{{a}}=0:Ann:Role", "{{b}}=1:2:3"} {
+		if err := f.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := f.String(); s != "{{a}}=0:Ann:Role {{b}}=1:2:3" {
+		t.Errorf("String() = %q, want the repeated flags", s)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hflabstesttask/gdocs"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Tables found on the pages of all the jobs, before merging and selecting them.
+var tablesFound atomic.Int64
+
+type runStats struct {
+	Tables         int64   `json:"tables"`
+	Rows           int64   `json:"rows"`
+	Cells          int64   `json:"cells"`
+	APICalls       int64   `json:"api_calls"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+func collectStats(start time.Time) runStats {
+	docsStats := gdocs.CurrentStats()
+	return runStats{
+		Tables:         tablesFound.Load(),
+		Rows:           docsStats.Rows,
+		Cells:          docsStats.Cells,
+		APICalls:       docsStats.APICalls,
+		ElapsedSeconds: time.Since(start).Seconds(),
+	}
+}
+
+// Writes the summary of the run as one line, key=value pairs or a JSON object.
+func printStats(w io.Writer, stats runStats, asJson bool) error {
+	if asJson {
+		return json.NewEncoder(w).Encode(stats)
+	}
+
+	_, err := fmt.Fprintf(w, "tables=%v rows=%v cells=%v api_calls=%v elapsed=%.3fs\n", stats.Tables, stats.Rows, stats.Cells, stats.APICalls, stats.ElapsedSeconds)
+	return err
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"hflabstesttask/syncer"
+	"io"
+	"runtime"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var version = "dev"
+var commit = "unknown"
+var buildDate = "unknown"
+
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "hflabstesttask %v (commit %v, built %v, %v)\n", version, commit, buildDate, runtime.Version())
+}
+
+// The default User-Agent of the Confluence requests, such as HFLabsTableSync/1.2.0.
+func userAgent() string {
+	return syncer.USER_AGENT + "/" + version
+}
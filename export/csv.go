@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func WriteTableCSV(tbl scrape.Table, w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	for _, row := range tbl.Contents {
+		if err := csvWriter.Write(row.Texts()); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func WriteTablesCSV(tables []scrape.Table, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, tbl := range tables {
+		path := filepath.Join(dir, fmt.Sprintf("table_%v.csv", i))
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		err = WriteTableCSV(tbl, f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to write %v: %v", path, err)
+		}
+	}
+
+	return nil
+}
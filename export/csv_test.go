@@ -0,0 +1,47 @@
+package export
+
+import (
+	"hflabstesttask/scrape"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tables with the cells the formats have to escape: separators, quotes, line breaks and Cyrillic text.
+func testTables() []scrape.Table {
+	header := scrape.NewRow("Name", "Comment", "Score")
+	header.Header = true
+	return []scrape.Table{
+		{
+			Heading: "Users",
+			Caption: "Active users",
+			Contents: []scrape.Row{
+				header,
+				scrape.NewRow("Ann", `Says "hi", often`, "10"),
+				scrape.NewRow("Борис", "a | b\nsecond line", "7"),
+			},
+		},
+		{Contents: []scrape.Row{scrape.NewRow("x", ""), scrape.NewRow("", "y")}},
+	}
+}
+
+func TestWriteTablesCSV(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "csv")
+	if err := WriteTablesCSV(testTables(), dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"table_0.csv", "table_1.csv"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%v =\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"strings"
+)
+
+// Prints the diff one change per line, rows and columns are 0-based:
+//
+//	Table #0 (Users):
+//	  - row 3: Bob | 25
+//	  + row 4: Eve | 41
+//	  ~ row 1 col 1: "30" -> "31"
+func WriteDiffText(diff scrape.Diff, w io.Writer) error {
+	if diff.Empty() {
+		_, err := fmt.Fprintln(w, "No changes since the last run")
+		return err
+	}
+
+	for _, tableDiff := range diff.Tables {
+		fmt.Fprintf(w, "Table #%v", tableDiff.Index)
+		if tableDiff.Heading != "" {
+			fmt.Fprintf(w, " (%v)", tableDiff.Heading)
+		}
+		switch {
+		case tableDiff.Added:
+			fmt.Fprint(w, " added")
+		case tableDiff.Removed:
+			fmt.Fprint(w, " removed")
+		}
+		fmt.Fprintln(w, ":")
+
+		for _, row := range tableDiff.RemovedRows {
+			fmt.Fprintf(w, "  - row %v: %v\n", row.Row, strings.Join(row.Texts, " | "))
+		}
+		for _, row := range tableDiff.AddedRows {
+			fmt.Fprintf(w, "  + row %v: %v\n", row.Row, strings.Join(row.Texts, " | "))
+		}
+		for _, cell := range tableDiff.ChangedCells {
+			fmt.Fprintf(w, "  ~ row %v col %v: %q -> %q\n", cell.Row, cell.Col, cell.Old, cell.New)
+		}
+	}
+	return nil
+}
+
+func WriteDiffJSON(diff scrape.Diff, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diff)
+}
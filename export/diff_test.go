@@ -0,0 +1,43 @@
+package export
+
+import (
+	"bytes"
+	"hflabstesttask/scrape"
+	"testing"
+)
+
+func TestWriteDiffText(t *testing.T) {
+	tests := []struct {
+		name string
+		diff scrape.Diff
+		want string
+	}{
+		{"no changes", scrape.Diff{Tables: []scrape.TableDiff{}}, "No changes since the last run\n"},
+		{
+			"changes",
+			scrape.Diff{Tables: []scrape.TableDiff{
+				{
+					Index:        0,
+					Heading:      "Users",
+					RemovedRows:  []scrape.RowChange{{Row: 3, Texts: []string{"Bob", "25"}}},
+					AddedRows:    []scrape.RowChange{{Row: 4, Texts: []string{"Eve", "41"}}},
+					ChangedCells: []scrape.CellChange{{Row: 1, Col: 1, Old: "30", New: "31"}},
+				},
+				{Index: 2, Added: true, AddedRows: []scrape.RowChange{{Row: 0, Texts: []string{"a"}}}},
+			}},
+			"Table #0 (Users):\n  - row 3: Bob | 25\n  + row 4: Eve | 41\n  ~ row 1 col 1: \"30\" -> \"31\"\nTable #2 added:\n  + row 0: a\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			if err := WriteDiffText(test.diff, &buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.want {
+				t.Errorf("WriteDiffText() =\n%v\nwant\n%v", buf.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+)
+
+type tablesJSON struct {
+	Tables []tableJSON `json:"tables"`
+}
+
+type tableJSON struct {
+	Heading string `json:"heading,omitempty"`
+	Caption string `json:"caption,omitempty"`
+	// Indices of the rows made of th cells.
+	HeaderRows []int      `json:"header_rows,omitempty"`
+	Rows       [][]string `json:"rows"`
+}
+
+func WriteTablesJSON(tables []scrape.Table, w io.Writer) error {
+	dto := tablesJSON{Tables: make([]tableJSON, len(tables))}
+	for i, tbl := range tables {
+		dto.Tables[i].Heading = tbl.Heading
+		dto.Tables[i].Caption = tbl.Caption
+		dto.Tables[i].Rows = make([][]string, len(tbl.Contents))
+		for rowIdx, row := range tbl.Contents {
+			dto.Tables[i].Rows[rowIdx] = row.Texts()
+			if row.Header {
+				dto.Tables[i].HeaderRows = append(dto.Tables[i].HeaderRows, rowIdx)
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dto)
+}
+
+func ReadTablesJSON(r io.Reader) ([]scrape.Table, error) {
+	dto := tablesJSON{}
+	if err := json.NewDecoder(r).Decode(&dto); err != nil {
+		return nil, err
+	}
+
+	tables := make([]scrape.Table, len(dto.Tables))
+	for i, tblJSON := range dto.Tables {
+		tables[i].Heading = tblJSON.Heading
+		tables[i].Caption = tblJSON.Caption
+		tables[i].Contents = make([]scrape.Row, len(tblJSON.Rows))
+		for rowIdx, entries := range tblJSON.Rows {
+			tables[i].Contents[rowIdx] = scrape.NewRow(entries...)
+		}
+
+		for _, rowIdx := range tblJSON.HeaderRows {
+			if rowIdx < 0 || rowIdx >= len(tblJSON.Rows) {
+				return nil, fmt.Errorf("Header row #%v is out of range in table #%v", rowIdx, i)
+			}
+			tables[i].Contents[rowIdx].Header = true
+		}
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"bytes"
+	"hflabstesttask/scrape"
+	"strings"
+	"testing"
+)
+
+func TestTablesJSONRoundTrip(t *testing.T) {
+	tables := testTables()
+	buf := bytes.Buffer{}
+	if err := WriteTablesJSON(tables, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadTablesJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tables) {
+		t.Fatalf("ReadTablesJSON() returned %v tables, want %v", len(got), len(tables))
+	}
+	for i := range tables {
+		if got[i].Heading != tables[i].Heading || got[i].Caption != tables[i].Caption || len(got[i].Contents) != len(tables[i].Contents) {
+			t.Errorf("table %v = %+v, want %+v", i, got[i], tables[i])
+			continue
+		}
+		for j, row := range got[i].Contents {
+			want := tables[i].Contents[j]
+			if strings.Join(row.Texts(), "\x00") != strings.Join(want.Texts(), "\x00") || row.Header != want.Header {
+				t.Errorf("table %v row %v = %+v, want %+v", i, j, row, want)
+			}
+		}
+	}
+}
+
+func TestReadTablesJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []scrape.Table
+		err  string
+	}{
+		{"empty", `{"tables": []}`, []scrape.Table{}, ""},
+		{"header row", `{"tables": [{"header_rows": [0], "rows": [["a"], ["b"]]}]}`, []scrape.Table{{Contents: []scrape.Row{{Cells: []scrape.Cell{{Text: "a"}}, Header: true}, scrape.NewRow("b")}}}, ""},
+		{"header out of range", `{"tables": [{"header_rows": [2], "rows": [["a"]]}]}`, nil, "Header row #2 is out of range in table #0"},
+		{"negative header", `{"tables": [{"header_rows": [-1], "rows": [["a"]]}]}`, nil, "Header row #-1 is out of range in table #0"},
+		{"invalid", `{"tables": [`, nil, "unexpected EOF"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReadTablesJSON(strings.NewReader(test.in))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("ReadTablesJSON() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("ReadTablesJSON() = %+v, want %+v", got, test.want)
+			}
+			for i := range got {
+				for j, row := range got[i].Contents {
+					if row.Texts()[0] != test.want[i].Contents[j].Texts()[0] || row.Header != test.want[i].Contents[j].Header {
+						t.Errorf("table %v row %v = %+v, want %+v", i, j, row, test.want[i].Contents[j])
+					}
+				}
+			}
+		})
+	}
+}
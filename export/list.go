@@ -0,0 +1,53 @@
+package export
+
+import (
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Joins the text of every cell, so that tables with the same contents get the same key.
+func contentsKey(tbl scrape.Table) string {
+	b := strings.Builder{}
+	for _, row := range tbl.Contents {
+		for _, cell := range row.Cells {
+			b.WriteString(cell.Text)
+			b.WriteByte(0)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Prints one line per table with its index, dimensions, heading and first row, so that the user can pick
+// a -table-index. Tables with the same contents as an earlier one are marked as its duplicates.
+func ListTables(tables []scrape.Table, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tSIZE\tHEADING\tFIRST ROW")
+
+	seen := map[string]int{}
+	for i, tbl := range tables {
+		colCnt := 0
+		firstRow := []string{}
+		if len(tbl.Contents) > 0 {
+			colCnt = len(tbl.Contents[0].Cells)
+			for _, cell := range tbl.Contents[0].Cells {
+				firstRow = append(firstRow, previewCell(cell.Text))
+			}
+		}
+
+		fmt.Fprintf(tw, "%v\t%vx%v\t%v\t%v", i, len(tbl.Contents), colCnt, previewCell(tbl.Heading), strings.Join(firstRow, " | "))
+
+		key := contentsKey(tbl)
+		if first, ok := seen[key]; ok {
+			fmt.Fprintf(tw, "  (duplicate of #%v)", first)
+		} else {
+			seen[key] = i
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
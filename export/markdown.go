@@ -0,0 +1,71 @@
+package export
+
+import (
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"strings"
+)
+
+var markdownCellReplacer = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+
+func markdownRow(entries []string) string {
+	cells := make([]string, len(entries))
+	for i, entry := range entries {
+		cells[i] = markdownCellReplacer.Replace(strings.TrimSpace(entry))
+	}
+
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+// Writes tbl as a GitHub-flavored Markdown table. A th row at the top becomes the Markdown header,
+// otherwise the header is left empty, since GFM tables can't be written without one.
+func WriteTableMarkdown(tbl scrape.Table, w io.Writer) error {
+	if len(tbl.Contents) == 0 {
+		return nil
+	}
+
+	rows := tbl.Contents
+	header := make([]string, len(rows[0].Cells))
+	if rows[0].Header {
+		header = rows[0].Texts()
+		rows = rows[1:]
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	if _, err := io.WriteString(w, markdownRow(header)+"| "+strings.Join(separator, " | ")+" |\n"); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if _, err := io.WriteString(w, markdownRow(row.Texts())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteTablesMarkdown(tables []scrape.Table, w io.Writer) error {
+	for i, tbl := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if tbl.Heading != "" {
+			fmt.Fprintf(w, "## %v\n\n", tbl.Heading)
+		}
+		if tbl.Caption != "" {
+			fmt.Fprintf(w, "*%v*\n\n", tbl.Caption)
+		}
+
+		if err := WriteTableMarkdown(tbl, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
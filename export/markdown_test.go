@@ -0,0 +1,58 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata with the current output")
+
+// Compares got with testdata/name, or rewrites the file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %v, rerun with -update if the change is intended:\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+func TestWriteTablesMarkdown(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := WriteTablesMarkdown(testTables(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "tables.md.golden", buf.Bytes())
+}
+
+func TestMarkdownRow(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{}, "|  |\n"},
+		{[]string{"a", "b"}, "| a | b |\n"},
+		{[]string{" padded "}, "| padded |\n"},
+		{[]string{"a|b"}, "| a\\|b |\n"},
+		{[]string{"one\ntwo", "three\r\nfour"}, "| one<br>two | three<br>four |\n"},
+	}
+
+	for _, test := range tests {
+		if got := markdownRow(test.in); got != test.want {
+			t.Errorf("markdownRow(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
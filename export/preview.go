@@ -0,0 +1,63 @@
+package export
+
+import (
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const PREVIEW_ROWS = 5
+const PREVIEW_CELL_RUNES = 30
+
+// Shortens cell text to a single line of at most PREVIEW_CELL_RUNES runes.
+func previewCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) > PREVIEW_CELL_RUNES {
+		return string(runes[:PREVIEW_CELL_RUNES-1]) + "…"
+	}
+
+	return s
+}
+
+// Prints the dimensions and the first rows of every table as an aligned grid.
+func PrintTables(tables []scrape.Table, w io.Writer) error {
+	for i, tbl := range tables {
+		colCnt := 0
+		if len(tbl.Contents) > 0 {
+			colCnt = len(tbl.Contents[0].Cells)
+		}
+
+		fmt.Fprintf(w, "Table #%v: %v rows x %v cols", i, len(tbl.Contents), colCnt)
+		if tbl.Heading != "" {
+			fmt.Fprintf(w, " (%v)", tbl.Heading)
+		}
+		if tbl.Caption != "" {
+			fmt.Fprintf(w, " caption %q", tbl.Caption)
+		}
+		fmt.Fprintln(w)
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for rowIdx, row := range tbl.Contents {
+			if rowIdx == PREVIEW_ROWS {
+				fmt.Fprintf(tw, "  ... %v more row(s)\n", len(tbl.Contents)-PREVIEW_ROWS)
+				break
+			}
+
+			cells := make([]string, len(row.Cells))
+			for j, cell := range row.Cells {
+				cells[j] = previewCell(cell.Text)
+			}
+			fmt.Fprintf(tw, "  %v\n", strings.Join(cells, "\t| "))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
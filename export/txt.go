@@ -0,0 +1,92 @@
+package export
+
+import (
+	"fmt"
+	"hflabstesttask/scrape"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Collapses the cell to one line, line breaks and tabs would break the alignment.
+func textCell(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Pads s with spaces to width runes. Runes are counted rather than bytes, so Cyrillic text aligns
+// like Latin text, but wide characters such as CJK take two columns and may shift the rest of the row.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
+
+// Writes tbl as plain-text columns separated by |, with a line of dashes below the th rows at the top.
+func WriteTableText(tbl scrape.Table, w io.Writer) error {
+	rows := make([][]string, len(tbl.Contents))
+	widths := []int{}
+	for rowIdx, row := range tbl.Contents {
+		rows[rowIdx] = make([]string, len(row.Cells))
+		for col, cell := range row.Cells {
+			text := textCell(cell.Text)
+			rows[rowIdx][col] = text
+			if col >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[col] = max(widths[col], utf8.RuneCountInString(text))
+		}
+	}
+
+	headerCnt := 0
+	for headerCnt < len(tbl.Contents) && tbl.Contents[headerCnt].Header {
+		headerCnt++
+	}
+
+	for rowIdx, entries := range rows {
+		cells := make([]string, len(widths))
+		for col, width := range widths {
+			entry := ""
+			if col < len(entries) {
+				entry = entries[col]
+			}
+			cells[col] = padRight(entry, width)
+		}
+		if _, err := io.WriteString(w, strings.TrimRight(strings.Join(cells, " | "), " ")+"\n"); err != nil {
+			return err
+		}
+
+		if rowIdx == headerCnt-1 && headerCnt < len(rows) {
+			dashes := make([]string, len(widths))
+			for col, width := range widths {
+				dashes[col] = strings.Repeat("-", width)
+			}
+			if _, err := io.WriteString(w, strings.Join(dashes, "-+-")+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Writes the tables as plain text, each preceded by its heading and caption and separated by a blank line.
+func WriteTablesText(tables []scrape.Table, w io.Writer) error {
+	for i, tbl := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if tbl.Heading != "" {
+			fmt.Fprintf(w, "%v\n", textCell(tbl.Heading))
+		}
+		if tbl.Caption != "" {
+			fmt.Fprintf(w, "%v\n", tbl.Caption)
+		}
+		if tbl.Heading != "" || tbl.Caption != "" {
+			fmt.Fprintln(w)
+		}
+
+		if err := WriteTableText(tbl, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
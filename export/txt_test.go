@@ -0,0 +1,33 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTablesText(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := WriteTablesText(testTables(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "tables.txt.golden", buf.Bytes())
+}
+
+func TestPadRight(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"ab", 4, "ab  "},
+		{"Борис", 6, "Борис "},
+		{"long", 2, "long"},
+		{"", 0, ""},
+	}
+
+	for _, test := range tests {
+		if got := padRight(test.in, test.width); got != test.want {
+			t.Errorf("padRight(%q, %v) = %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"hflabstesttask/scrape"
+	"io"
+	"os"
+)
+
+// An output backend for the scraped tables.
+type Writer interface {
+	Write(tables []scrape.Table) error
+}
+
+// Calls write with the file at path, or with stdout when path is "-".
+func WriteToPath(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Writes every table to table_<index>.csv in Dir.
+type CSVWriter struct {
+	Dir string
+}
+
+func (w CSVWriter) Write(tables []scrape.Table) error {
+	return WriteTablesCSV(tables, w.Dir)
+}
+
+// Writes the tables as JSON to Path, "-" is stdout.
+type JSONWriter struct {
+	Path string
+}
+
+func (w JSONWriter) Write(tables []scrape.Table) error {
+	return WriteToPath(w.Path, func(out io.Writer) error {
+		return WriteTablesJSON(tables, out)
+	})
+}
+
+// Writes the tables as GitHub-flavored Markdown to Path, "-" is stdout.
+type MarkdownWriter struct {
+	Path string
+}
+
+func (w MarkdownWriter) Write(tables []scrape.Table) error {
+	return WriteToPath(w.Path, func(out io.Writer) error {
+		return WriteTablesMarkdown(tables, out)
+	})
+}
+
+// Writes the tables as aligned plain-text columns to Path, "-" is stdout.
+type TextWriter struct {
+	Path string
+}
+
+func (w TextWriter) Write(tables []scrape.Table) error {
+	return WriteToPath(w.Path, func(out io.Writer) error {
+		return WriteTablesText(tables, out)
+	})
+}
@@ -0,0 +1,483 @@
+package gdocs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Bounds a token refresh, which happens under the lock of the token file, well below LOCK_TIMEOUT,
+// so that a hung refresh doesn't make the other instances give up waiting for the lock.
+const TOKEN_REFRESH_TIMEOUT = 10 * time.Second
+
+// Writes every new token it refreshes to path, so refreshed access tokens survive restarts.
+// The lock of path is held from the refresh to the save, so that instances sharing the token refresh it
+// one at a time, and an instance that waited for the lock takes the token the other one saved.
+type persistingTokenSource struct {
+	ctx    context.Context
+	config *oauth2.Config
+	path   string
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+func newPersistingTokenSource(ctx context.Context, config *oauth2.Config, path string, tok *oauth2.Token) *persistingTokenSource {
+	return &persistingTokenSource{ctx: ctx, config: config, path: path, last: tok}
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockTokenFile(s.path)
+	if err != nil {
+		// Refreshing without the lock is what a single instance does anyway.
+		slog.Warn("Unable to lock the OAuth token, refreshing it without the lock", "err", err)
+		unlock = func() {}
+	}
+	defer unlock()
+
+	if saved, err := readToken(s.path); err == nil && saved.Valid() && saved.AccessToken != s.last.AccessToken {
+		s.last = saved
+		return saved, nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, TOKEN_REFRESH_TIMEOUT)
+	defer cancel()
+	tok, err := s.config.TokenSource(ctx, s.last).Token()
+	if err != nil {
+		return nil, err
+	}
+	if s.last.AccessToken != tok.AccessToken {
+		slog.Info("Saving OAuth token", "path", s.path)
+		if err := writeToken(s.path, tok); err != nil {
+			slog.Warn("Unable to persist the refreshed OAuth token", "err", err)
+		}
+		s.last = tok
+	}
+
+	return tok, nil
+}
+
+// Builds a client from tok and makes sure it is usable, refreshing it right away if it has expired.
+func clientFromToken(ctx context.Context, config *oauth2.Config, tokenPath string, tok *oauth2.Token) (*http.Client, error) {
+	source := oauth2.ReuseTokenSource(tok, newPersistingTokenSource(ctx, config, tokenPath, tok))
+
+	if _, err := source.Token(); err != nil {
+		return nil, err
+	}
+
+	return oauth2.NewClient(ctx, source), nil
+}
+
+// Retrieves a token, saves the token, then returns the generated client.
+func getClient(ctx context.Context, config *oauth2.Config, tokenPath string) (*http.Client, error) {
+	tok, err := tokenFromFile(tokenPath)
+	// Only a missing token is authorized in the browser, which would hang a run without a user.
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Unable to read OAuth token: %v, remove %v to authorize again", err, tokenPath)
+	}
+	if err == nil {
+		client, err := clientFromToken(ctx, config, tokenPath, tok)
+		if err == nil {
+			return client, nil
+		}
+
+		// A RetrieveError means Google rejected the refresh token, anything else may be transient.
+		retrieveErr := &oauth2.RetrieveError{}
+		if !errors.As(err, &retrieveErr) {
+			return nil, fmt.Errorf("Unable to refresh OAuth token: %v", err)
+		}
+
+		slog.Warn("The saved OAuth token was rejected, authorizing again", "path", tokenPath, "err", err)
+		if err := os.Remove(tokenPath); err != nil {
+			return nil, fmt.Errorf("Unable to remove stale OAuth token: %v", err)
+		}
+	}
+
+	tok, err = getTokenFromWeb(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveToken(tokenPath, tok); err != nil {
+		return nil, err
+	}
+	return clientFromToken(ctx, config, tokenPath, tok)
+}
+
+// Builds a client from the token JSON b. There is no file to fall back to, so a rejected token is an error.
+// The token never touches the token file: it isn't swapped for the saved one, and its refreshed copies
+// only live in memory, so that a token kept in the environment doesn't end up on disk.
+func clientFromGivenToken(ctx context.Context, config *oauth2.Config, b []byte) (*http.Client, error) {
+	tok := &oauth2.Token{}
+	if err := json.Unmarshal(b, tok); err != nil {
+		return nil, fmt.Errorf("Unable to parse the given OAuth token: %v", err)
+	}
+
+	source := config.TokenSource(ctx, tok)
+	if _, err := source.Token(); err != nil {
+		return nil, fmt.Errorf("Unable to refresh the given OAuth token: %v", err)
+	}
+	return oauth2.NewClient(ctx, source), nil
+}
+
+const AUTH_CALLBACK_TIMEOUT = 5 * time.Minute
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// Requests a token from the web, then returns the retrieved token. The authorization code is caught
+// by a temporary server on a loopback port, if no browser can be opened the user pastes it by hand.
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		slog.Warn("Unable to listen for the OAuth callback, falling back to pasting the code", "err", err)
+		return getTokenFromPaste(ctx, config)
+	}
+	defer listener.Close()
+
+	state, err := randomState()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate OAuth state: %v", err)
+	}
+
+	loopbackConfig := *config
+	loopbackConfig.RedirectURL = fmt.Sprintf("http://%v/", listener.Addr())
+	authURL := loopbackConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	if err := openBrowser(authURL); err != nil {
+		slog.Warn("Unable to open a browser, falling back to pasting the code", "err", err)
+		return getTokenFromPaste(ctx, config)
+	}
+	fmt.Fprintf(os.Stderr, "Your browser has been opened to visit: \n%v\n", authURL)
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		// Requests without our state, such as favicon.ico, are not the callback.
+		if query.Get("state") != state {
+			http.Error(w, "Invalid OAuth state", http.StatusBadRequest)
+			return
+		}
+
+		if authErr := query.Get("error"); authErr != "" {
+			fmt.Fprintln(w, "Authorization failed, you can close this tab.")
+			select {
+			case errs <- fmt.Errorf("The authorization was declined: %v", authErr):
+			default:
+			}
+			return
+		}
+
+		fmt.Fprintln(w, "Authorization complete, you can close this tab.")
+		select {
+		case codes <- query.Get("code"):
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	select {
+	case code := <-codes:
+		tok, err := loopbackConfig.Exchange(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to retrieve token from web: %v", err)
+		}
+		return tok, nil
+	case err := <-errs:
+		return nil, err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("Authorization cancelled: %w", ctx.Err())
+	case <-time.After(AUTH_CALLBACK_TIMEOUT):
+		return nil, fmt.Errorf("Timed out waiting for the OAuth callback after %v", AUTH_CALLBACK_TIMEOUT)
+	}
+}
+
+// Requests a token from the web by having the user paste the authorization code.
+func getTokenFromPaste(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	fmt.Fprintf(os.Stderr, "Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	var authCode string
+	if _, err := fmt.Scan(&authCode); err != nil {
+		return nil, fmt.Errorf("Unable to read authorization code: %v", err)
+	}
+
+	tok, err := config.Exchange(ctx, authCode)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve token from web: %v", err)
+	}
+	return tok, nil
+}
+
+// Takes the lock of the token file. A lock that can't be created for lack of permission, as in a read-only
+// directory, is skipped, the token can't be replaced there by anyone, so there is no write to wait for.
+func lockTokenFile(path string) (func(), error) {
+	unlock, err := lockFile(path)
+	if errors.Is(err, os.ErrPermission) {
+		slog.Debug("Unable to create the lock of the OAuth token, going on without it", "path", path, "err", err)
+		return func() {}, nil
+	}
+	return unlock, err
+}
+
+// Retrieves a token from a local file, under the lock of the file so that a concurrent saveToken
+// of another instance isn't read half-written.
+func tokenFromFile(file string) (*oauth2.Token, error) {
+	unlock, err := lockTokenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return readToken(file)
+}
+
+func readToken(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tok := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+// Saves a token to a file path. Instances saving the token at once take turns through the lock of the file.
+func saveToken(path string, token *oauth2.Token) error {
+	slog.Info("Saving OAuth token", "path", path)
+	unlock, err := lockTokenFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to cache OAuth token: %v", err)
+	}
+	defer unlock()
+
+	return writeToken(path, token)
+}
+
+// Writes the token to a temporary file renamed over path, so path is never left truncated. The caller holds the lock.
+func writeToken(path string, token *oauth2.Token) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to cache OAuth token: %v", err)
+	}
+
+	err = json.NewEncoder(f).Encode(token)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("Unable to cache OAuth token: %v", err)
+	}
+	return nil
+}
+
+const AUTH_MODE_AUTO = "auto"
+const AUTH_MODE_OAUTH = "oauth"
+const AUTH_MODE_SERVICE_ACCOUNT = "service-account"
+
+const DOCUMENTS_SCOPE = "https://www.googleapis.com/auth/documents"
+const DOCUMENTS_READONLY_SCOPE = "https://www.googleapis.com/auth/documents.readonly"
+
+// Access to the Drive files the app created or was given, enough to rename the document.
+const DRIVE_FILE_SCOPE = "https://www.googleapis.com/auth/drive.file"
+
+// Access to all the Drive files of the user, needed to create files in a folder the app didn't create.
+const DRIVE_SCOPE = "https://www.googleapis.com/auth/drive"
+
+type AuthOptions struct {
+	CredentialsPath string
+	TokenPath       string
+	// Contents of the credentials and the OAuth token, used instead of reading the files.
+	// A given token isn't replaced by authorizing again, and TokenPath is neither read nor written then.
+	Credentials []byte
+	Token       []byte
+	// One of the AUTH_MODE_* constants, AUTH_MODE_AUTO picks the mode from the credentials file type.
+	Mode string
+	// User to impersonate with a service account that has domain-wide delegation, optional.
+	Subject string
+	// Maximum number of Docs API requests per second, 0 means no limit.
+	QPS float64
+	// Also request DRIVE_FILE_SCOPE, so that GetDriveService works. A token saved without
+	// the scope keeps lacking it, remove the token file to authorize again.
+	DriveAccess bool
+	// Request DRIVE_SCOPE instead of DRIVE_FILE_SCOPE, so that documents can be created in any folder,
+	// Shared Drive folders included. Implies DriveAccess.
+	FullDriveAccess bool
+	// Request DOCUMENTS_READONLY_SCOPE instead of DOCUMENTS_SCOPE, the documents can be read but not written.
+	ReadOnly bool
+	// Log the requests and responses of the API with their bodies, the credentials are redacted.
+	Trace bool
+}
+
+// Detects the auth mode from the "type" field of the credentials file, OAuth client secrets don't have one.
+func detectAuthMode(credentials []byte) string {
+	file := struct {
+		Type string `json:"type"`
+	}{}
+	if json.Unmarshal(credentials, &file) == nil && file.Type == "service_account" {
+		return AUTH_MODE_SERVICE_ACCOUNT
+	}
+
+	return AUTH_MODE_OAUTH
+}
+
+func (opts AuthOptions) scopes() []string {
+	scopes := []string{DOCUMENTS_SCOPE}
+	if opts.ReadOnly {
+		scopes = []string{DOCUMENTS_READONLY_SCOPE}
+	}
+	if opts.FullDriveAccess {
+		scopes = append(scopes, DRIVE_SCOPE)
+	} else if opts.DriveAccess {
+		scopes = append(scopes, DRIVE_FILE_SCOPE)
+	}
+	return scopes
+}
+
+// Parsed credentials file, the config of its mode is set.
+type credentials struct {
+	mode  string
+	oauth *oauth2.Config
+	jwt   *jwt.Config
+}
+
+// Reads and parses the credentials of opts, detecting the auth mode if needed.
+func loadCredentials(opts AuthOptions) (*credentials, error) {
+	b := opts.Credentials
+	if len(b) == 0 {
+		var err error
+		b, err = os.ReadFile(opts.CredentialsPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read client secret file: %v", err)
+		}
+	}
+
+	creds := &credentials{mode: opts.Mode}
+	if creds.mode == AUTH_MODE_AUTO {
+		creds.mode = detectAuthMode(b)
+	}
+
+	var err error
+	switch creds.mode {
+	case AUTH_MODE_OAUTH:
+		creds.oauth, err = google.ConfigFromJSON(b, opts.scopes()...)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse client secret file to config: %v", err)
+		}
+	case AUTH_MODE_SERVICE_ACCOUNT:
+		creds.jwt, err = google.JWTConfigFromJSON(b, opts.scopes()...)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse service account key file: %v", err)
+		}
+		creds.jwt.Subject = opts.Subject
+	default:
+		return nil, fmt.Errorf("Unknown auth mode %q", opts.Mode)
+	}
+
+	return creds, nil
+}
+
+// Builds the HTTP client authorized with creds. An OAuth token is obtained right away, authorizing
+// in the browser if needed, while a service account only gets its token on the first request.
+func (creds *credentials) client(ctx context.Context, opts AuthOptions) (*http.Client, error) {
+	if creds.jwt != nil {
+		return creds.jwt.Client(ctx), nil
+	}
+
+	if len(opts.Token) > 0 {
+		return clientFromGivenToken(ctx, creds.oauth, opts.Token)
+	}
+	return getClient(ctx, creds.oauth, opts.TokenPath)
+}
+
+// Builds the rate limited HTTP client authorized with the credentials of opts.
+func getHttpClient(ctx context.Context, opts AuthOptions) (*http.Client, error) {
+	creds, err := loadCredentials(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := creds.client(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Trace {
+		client = tracedClient(client)
+	}
+	return rateLimitedClient(client, opts.QPS), nil
+}
+
+func GetService(ctx context.Context, opts AuthOptions) (*docs.Service, error) {
+	client, err := getHttpClient(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+
+	srv, err := docs.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve Docs client: %v", err)
+	}
+
+	return srv, nil
+}
+
+// Returns a Drive client, opts.DriveAccess or opts.FullDriveAccess must be set.
+func GetDriveService(ctx context.Context, opts AuthOptions) (*drive.Service, error) {
+	client, err := getHttpClient(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve Drive client: %v", err)
+	}
+
+	return srv, nil
+}
@@ -0,0 +1,159 @@
+package gdocs
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSaveTokenConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	const writers = 10
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- saveToken(path, &oauth2.Token{AccessToken: fmt.Sprintf("token-%v", i), TokenType: "Bearer"})
+		}(i)
+		// Readers never see a half-written token.
+		go func() {
+			defer wg.Done()
+			if _, err := tokenFromFile(path); err != nil && !os.IsNotExist(err) {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	tok, err := tokenFromFile(path)
+	if err != nil {
+		t.Fatalf("tokenFromFile() = %v, want the last saved token", err)
+	}
+	if tok.TokenType != "Bearer" || len(tok.AccessToken) < len("token-0") {
+		t.Errorf("tokenFromFile() = %+v, want one of the saved tokens", tok)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("%v is left behind", path+".tmp")
+	}
+}
+
+func TestPersistingTokenSourceRefreshesOnce(t *testing.T) {
+	refreshes := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := refreshes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "fresh-%v", "token_type": "Bearer", "refresh_token": "refresh", "expires_in": 3600}`, n)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	expired := &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+	if err := saveToken(path, expired); err != nil {
+		t.Fatal(err)
+	}
+	config := &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: srv.URL}}
+
+	// Two instances sharing the token file, both holding the expired token.
+	sources := []*persistingTokenSource{
+		newPersistingTokenSource(context.Background(), config, path, expired),
+		newPersistingTokenSource(context.Background(), config, path, expired),
+	}
+	tokens := make([]*oauth2.Token, len(sources))
+	wg := sync.WaitGroup{}
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source *persistingTokenSource) {
+			defer wg.Done()
+			tok, err := source.Token()
+			if err != nil {
+				t.Error(err)
+			}
+			tokens[i] = tok
+		}(i, source)
+	}
+	wg.Wait()
+
+	if refreshes.Load() != 1 {
+		t.Errorf("the token was refreshed %v times, want once", refreshes.Load())
+	}
+	if tokens[0] == nil || tokens[1] == nil || tokens[0].AccessToken != "fresh-1" || tokens[1].AccessToken != "fresh-1" {
+		t.Errorf("Token() = %v and %v, want both fresh-1", tokens[0], tokens[1])
+	}
+	if saved, err := tokenFromFile(path); err != nil || saved.AccessToken != "fresh-1" {
+		t.Errorf("saved token = %v, %v, want fresh-1", saved, err)
+	}
+}
+
+func TestTokenFromReadOnlyDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to a read-only directory")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	if err := saveToken(path, &oauth2.Token{AccessToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0500); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0700)
+
+	if tok, err := tokenFromFile(path); err != nil || tok.AccessToken != "token" {
+		t.Errorf("tokenFromFile() = %v, %v, want the token without a lock", tok, err)
+	}
+}
+
+// An expired token of HFLABS_TOKEN is refreshed, not swapped for the token in the file, which may belong to
+// another account, and the refreshed copy isn't saved.
+func TestGivenTokenIgnoresTokenFile(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "fresh-given", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer tokenSrv.Close()
+	authorization := ""
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer apiSrv.Close()
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	onDisk := &oauth2.Token{AccessToken: "other-account", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if err := saveToken(path, onDisk); err != nil {
+		t.Fatal(err)
+	}
+	given := `{"access_token": "expired", "refresh_token": "refresh", "expiry": "2020-01-01T00:00:00Z"}`
+	config := &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: tokenSrv.URL}}
+
+	client, err := (&credentials{oauth: config}).client(context.Background(), AuthOptions{TokenPath: path, Token: []byte(given)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(apiSrv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer fresh-given" {
+		t.Errorf("Authorization = %q, want the refreshed given token", authorization)
+	}
+	if saved, err := tokenFromFile(path); err != nil || saved.AccessToken != onDisk.AccessToken {
+		t.Errorf("saved token = %v, %v, want the token file left as it is", saved, err)
+	}
+}
@@ -0,0 +1,70 @@
+package gdocs
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/option"
+)
+
+// Outcome of a step of CheckAccess.
+type CheckResult struct {
+	Step   string
+	Detail string
+	Err    error
+}
+
+// Checks the Google setup step by step without changing any document: the credentials parse, a token
+// can be obtained and every document of docIds can be read. An empty id is a document that will be
+// created, so there is nothing to read. Stops at the first failed step, which is the last result.
+func CheckAccess(ctx context.Context, opts AuthOptions, docIds []string) []CheckResult {
+	results := []CheckResult{}
+	fail := func(step string, err error) []CheckResult {
+		return append(results, CheckResult{Step: step, Err: err})
+	}
+
+	creds, err := loadCredentials(opts)
+	if err != nil {
+		return fail("credentials", err)
+	}
+	source := opts.CredentialsPath
+	if len(opts.Credentials) > 0 {
+		source = "the given credentials"
+	}
+	results = append(results, CheckResult{Step: "credentials", Detail: fmt.Sprintf("%v from %v", creds.mode, source)})
+
+	client, err := creds.client(ctx, opts)
+	if err != nil {
+		return fail("token", err)
+	}
+	detail := "OAuth token from " + opts.TokenPath
+	if len(opts.Token) > 0 {
+		detail = "the given OAuth token"
+	}
+	if creds.jwt != nil {
+		if _, err := creds.jwt.TokenSource(ctx).Token(); err != nil {
+			return fail("token", err)
+		}
+		detail = "service account " + creds.jwt.Email
+	}
+	results = append(results, CheckResult{Step: "token", Detail: detail})
+
+	srv, err := docs.NewService(ctx, option.WithHTTPClient(rateLimitedClient(client, opts.QPS)))
+	if err != nil {
+		return fail("document", fmt.Errorf("Unable to retrieve Docs client: %v", err))
+	}
+	for _, docId := range docIds {
+		if docId == "" {
+			results = append(results, CheckResult{Step: "document", Detail: "none stored yet, a new one will be created"})
+			continue
+		}
+
+		doc, err := ValidateDocument(ctx, docId, srv)
+		if err != nil {
+			return fail("document", err)
+		}
+		results = append(results, CheckResult{Step: "document", Detail: fmt.Sprintf("%q (%v)", doc.Title, doc.DocumentId)})
+	}
+
+	return results
+}
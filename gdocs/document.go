@@ -0,0 +1,312 @@
+package gdocs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Does a lightweight Get of the document, turning the API errors into messages that say what to fix.
+// The Docs API can't check edit access without editing, so a read-only share is only caught on the first write.
+func ValidateDocument(ctx context.Context, docId string, srv *docs.Service) (*docs.Document, error) {
+	doc, err := srv.Documents.Get(docId).Fields("documentId", "title").Context(ctx).Do()
+	if err == nil {
+		return doc, nil
+	}
+
+	apiErr := &googleapi.Error{}
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("%w: %v not found, check the stored document id: %w", ErrDocumentUnavailable, docId, err)
+		case http.StatusForbidden:
+			return nil, fmt.Errorf("%w: no access to %v, share it with the authenticated account: %w", ErrDocumentUnavailable, docId, err)
+		}
+	}
+
+	return nil, err
+}
+
+// Reports whether err says the document is definitively unavailable to us, as opposed to a transient failure.
+// 403 is also used for rate limiting, so only a 403 without a rate limit reason counts.
+func documentGone(err error) bool {
+	apiErr := &googleapi.Error{}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case http.StatusNotFound:
+		return true
+	case http.StatusForbidden:
+		for _, item := range apiErr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Guards the read-modify-write of the document id files by concurrent jobs.
+var documentIdMu sync.Mutex
+
+// Reads the stored document ids by page URL, a missing file means no ids. A file holding a plain
+// document id, as written by older versions, is returned under the empty URL.
+func loadDocumentIds(path string) (map[string]string, error) {
+	ids := map[string]string{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read document id file: %v", err)
+	}
+
+	content := strings.TrimSpace(string(b))
+	if content == "" {
+		return ids, nil
+	}
+	if !strings.HasPrefix(content, "{") {
+		ids[""] = content
+		return ids, nil
+	}
+
+	if err := json.Unmarshal(b, &ids); err != nil {
+		return nil, fmt.Errorf("Unable to parse document id file %v: %v", path, err)
+	}
+	return ids, nil
+}
+
+// Stores docId as the document of pageURL, leaving the documents of the other URLs as they are.
+// A plain document id of an older version is dropped, it belongs to pageURL now.
+func storeDocumentId(path string, pageURL string, docId string) error {
+	documentIdMu.Lock()
+	defer documentIdMu.Unlock()
+
+	ids, err := loadDocumentIds(path)
+	if err != nil {
+		return err
+	}
+
+	delete(ids, "")
+	ids[pageURL] = docId
+	return writeDocumentIds(path, ids)
+}
+
+func writeDocumentIds(path string, ids map[string]string) error {
+	b, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, b, 0666); err != nil {
+		return fmt.Errorf("Unable to write document id file: %v", err)
+	}
+	return nil
+}
+
+// Returns the id stored for pageURL, or else the plain id of an older version with legacy set.
+func lookupDocumentId(path string, pageURL string) (docId string, legacy bool, err error) {
+	documentIdMu.Lock()
+	defer documentIdMu.Unlock()
+
+	ids, err := loadDocumentIds(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	if docId, ok := ids[pageURL]; ok {
+		return docId, false, nil
+	}
+	return ids[""], ids[""] != "", nil
+}
+
+// Like lookupDocumentId, but a plain id of an older version is claimed for pageURL right away, under the
+// same lock, so that of the jobs sharing the file only the first one gets it and the others create their
+// own documents instead of all clearing and rewriting the same one.
+func claimDocumentId(path string, pageURL string) (docId string, legacy bool, err error) {
+	documentIdMu.Lock()
+	defer documentIdMu.Unlock()
+
+	ids, err := loadDocumentIds(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	if docId, ok := ids[pageURL]; ok {
+		return docId, false, nil
+	}
+	docId = ids[""]
+	if docId == "" {
+		return "", false, nil
+	}
+
+	delete(ids, "")
+	ids[pageURL] = docId
+	if err := writeDocumentIds(path, ids); err != nil {
+		slog.Warn("Unable to store the document id", "document_id", docId, "err", err)
+	}
+	return docId, true, nil
+}
+
+// Returns the id of the document stored in documentIdPath for pageURL, empty if there is none yet.
+func StoredDocumentId(documentIdPath string, pageURL string) (string, error) {
+	docId, _, err := lookupDocumentId(documentIdPath, pageURL)
+	return docId, err
+}
+
+// Creates a document and stores its id in documentIdPath as the document of pageURL.
+// The language of a document, which spellcheck and autocorrect follow, can't be set here: neither
+// the Docs API nor the Drive API exposes it, it is only set in the editor with File > Language.
+func CreateDocument(ctx context.Context, srv *docs.Service, documentIdPath string, pageURL string, title string) (*docs.Document, error) {
+	doc, err := srv.Documents.Create(&docs.Document{Title: title}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	storeNewDocumentId(documentIdPath, pageURL, doc.DocumentId)
+	return doc, nil
+}
+
+// The document already exists, so failing to store its id isn't an error.
+func storeNewDocumentId(documentIdPath string, pageURL string, docId string) {
+	if err := storeDocumentId(documentIdPath, pageURL, docId); err != nil {
+		slog.Warn("Unable to store the new document id, the next run will create another document", "document_id", docId, "err", err)
+	}
+}
+
+const DOCUMENT_MIME_TYPE = "application/vnd.google-apps.document"
+
+// Like CreateDocument, but creates the document with Drive, in the folder folderId. The Docs API always
+// creates documents in the root of My Drive, while organizations may require them in a Shared Drive.
+func CreateDocumentInFolder(ctx context.Context, srv *docs.Service, driveSrv *drive.Service, documentIdPath string, pageURL string, title string, folderId string) (*docs.Document, error) {
+	file, err := driveSrv.Files.Create(&drive.File{
+		Name:     title,
+		MimeType: DOCUMENT_MIME_TYPE,
+		Parents:  []string{folderId},
+	}).SupportsAllDrives(true).Fields("id").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create document in folder %v: %w", folderId, err)
+	}
+
+	storeNewDocumentId(documentIdPath, pageURL, file.Id)
+	return ValidateDocument(ctx, file.Id, srv)
+}
+
+// Opens the document stored in documentIdPath for pageURL. Returns a nil document and no error
+// if the URL has no document yet or the stored one is gone, so that a new one has to be created.
+func OpenDocument(ctx context.Context, srv *docs.Service, documentIdPath string, pageURL string) (*docs.Document, error) {
+	docId, legacy, err := claimDocumentId(documentIdPath, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if docId == "" {
+		return nil, nil
+	}
+	if legacy {
+		slog.Info("Claimed the document id of the old document id file for the URL", "document_id", docId, "url", pageURL)
+	}
+
+	doc, err := ValidateDocument(ctx, docId, srv)
+	if err == nil {
+		return doc, nil
+	}
+	if !documentGone(err) {
+		return nil, err
+	}
+
+	slog.Warn("The stored document is gone, a new one will be created", "document_id", docId, "err", err)
+	return nil, nil
+}
+
+// Opens the document stored in documentIdPath for pageURL, creating a new one if there is none yet or the stored one is gone.
+func GetDocument(ctx context.Context, srv *docs.Service, documentIdPath string, pageURL string, title string) (*docs.Document, error) {
+	doc, err := OpenDocument(ctx, srv, documentIdPath, pageURL)
+	if doc != nil || err != nil {
+		return doc, err
+	}
+
+	return CreateDocument(ctx, srv, documentIdPath, pageURL, title)
+}
+
+// The Docs API can't change the title, it is the name of the file on Drive.
+func RenameDocument(ctx context.Context, srv *drive.Service, docId string, title string) error {
+	_, err := srv.Files.Update(docId, &drive.File{Name: title}).SupportsAllDrives(true).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("Unable to rename document %v: %w", docId, err)
+	}
+	return nil
+}
+
+// Returns the number of characters in the body of the document, 0 for an empty document.
+// The body always ends with a newline that can't be deleted, which isn't counted.
+func DocumentLength(ctx context.Context, docId string, srv *docs.Service) (int64, error) {
+	doc, err := srv.Documents.Get(docId).Fields("body/content/endIndex").Context(ctx).Do()
+	if err != nil {
+		return 0, err
+	}
+
+	if doc.Body == nil || len(doc.Body.Content) == 0 {
+		return 0, nil
+	}
+	// Index 0 is the section break that starts the body.
+	return max(doc.Body.Content[len(doc.Body.Content)-1].EndIndex-2, 0), nil
+}
+
+// Returns the range of the body that clearing deletes: everything after the section break that starts it,
+// except the final newline, which can't be deleted. A body without content, or with only the default empty
+// paragraph of a new document, has nothing to delete.
+func clearRange(body *docs.Body) (*docs.Range, bool) {
+	if body == nil || len(body.Content) == 0 {
+		return nil, false
+	}
+
+	startIndex := body.Content[0].StartIndex + 1
+	endIndex := body.Content[len(body.Content)-1].EndIndex - 1
+	if endIndex <= startIndex {
+		return nil, false
+	}
+	return &docs.Range{StartIndex: startIndex, EndIndex: endIndex}, true
+}
+
+func ClearDocument(ctx context.Context, docId string, srv *docs.Service) error {
+	doc, err := srv.Documents.Get(docId).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	deleteRange, ok := clearRange(doc.Body)
+	if !ok {
+		slog.Debug("The document body is already empty")
+		return nil
+	}
+	slog.Debug("Clearing document body", "start_index", deleteRange.StartIndex, "end_index", deleteRange.EndIndex)
+
+	_, err = batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests: []*docs.Request{
+			&docs.Request{
+				DeleteContentRange: &docs.DeleteContentRangeRequest{
+					Range: deleteRange,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
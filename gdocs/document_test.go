@@ -0,0 +1,90 @@
+package gdocs
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/internal/docstest"
+	"testing"
+)
+
+func TestClearRange(t *testing.T) {
+	sectionBreak := &docs.StructuralElement{StartIndex: 0, EndIndex: 1, SectionBreak: &docs.SectionBreak{}}
+	paragraph := func(start int64, end int64) *docs.StructuralElement {
+		return &docs.StructuralElement{StartIndex: start, EndIndex: end, Paragraph: &docs.Paragraph{}}
+	}
+	tests := []struct {
+		name string
+		body *docs.Body
+		want string
+	}{
+		{"nil body", nil, "none"},
+		{"no content", &docs.Body{}, "none"},
+		{"section break", &docs.Body{Content: []*docs.StructuralElement{sectionBreak}}, "none"},
+		{"empty paragraph", &docs.Body{Content: []*docs.StructuralElement{sectionBreak, paragraph(1, 2)}}, "none"},
+		{"text", &docs.Body{Content: []*docs.StructuralElement{sectionBreak, paragraph(1, 7)}}, "1-6"},
+		{"paragraphs", &docs.Body{Content: []*docs.StructuralElement{sectionBreak, paragraph(1, 7), paragraph(7, 12)}}, "1-11"},
+		// Only the first and the last element matter.
+		{"table", &docs.Body{Content: []*docs.StructuralElement{sectionBreak, {StartIndex: 1, EndIndex: 9, Table: &docs.Table{}}, paragraph(9, 10)}}, "1-9"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := "none"
+			if r, ok := clearRange(test.body); ok {
+				got = fmt.Sprintf("%v-%v", r.StartIndex, r.EndIndex)
+			}
+			if got != test.want {
+				t.Errorf("clearRange() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClearDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []docstest.Element
+		length   int64
+		batches  int
+	}{
+		{"new document", nil, 0, 0},
+		{"text", []docstest.Element{{Text: "Notes\n"}}, 5, 1},
+		{"table", []docstest.Element{{Text: "Heading\n", Style: "HEADING_2"}, {Cells: [][]string{{"a\n", "b\n"}}}, {Text: "\n"}}, 16, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := docstest.NewServer(t)
+			srv := fake.Service(t)
+			ctx := context.Background()
+			docId := fake.AddDocument("Tables", test.elements...)
+
+			length, err := DocumentLength(ctx, docId, srv)
+			if err != nil || length != test.length {
+				t.Errorf("DocumentLength() = %v, %v, want %v", length, err, test.length)
+			}
+
+			if err := ClearDocument(ctx, docId, srv); err != nil {
+				t.Fatal(err)
+			}
+			// The Docs API rejects an empty delete range, so an empty document isn't sent any.
+			if batches := fake.Batches(); len(batches) != test.batches {
+				t.Errorf("sent %v batch updates, want %v", len(batches), test.batches)
+			}
+			if elements := fake.Document(docId).Elements; len(elements) != 1 || elements[0].Text != "\n" {
+				t.Errorf("document = %q, want only the empty paragraph", elements)
+			}
+			if length, err := DocumentLength(ctx, docId, srv); err != nil || length != 0 {
+				t.Errorf("DocumentLength() after the clear = %v, %v, want 0", length, err)
+			}
+		})
+	}
+}
+
+func TestClearDocumentNotFound(t *testing.T) {
+	fake := docstest.NewServer(t)
+	if err := ClearDocument(context.Background(), "missing", fake.Service(t)); err == nil {
+		t.Errorf("ClearDocument() of a missing document succeeded")
+	}
+}
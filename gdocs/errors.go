@@ -0,0 +1,17 @@
+package gdocs
+
+import "errors"
+
+// Errors returned by this package wrap one of these, so that callers can tell the failures apart with errors.Is.
+var (
+	// Reading the credentials or getting a Google token failed.
+	ErrAuth = errors.New("Google authorization failed")
+	// The stored document doesn't exist or isn't shared with the authenticated account.
+	ErrDocumentUnavailable = errors.New("Document unavailable")
+	// The table has no rows or rows of different lengths, so it can't be inserted.
+	ErrMalformedTable = errors.New("Malformed table")
+	// The tables go over the size limits of Google Docs, see CheckSize.
+	ErrTooLarge = errors.New("Tables too large for Google Docs")
+	// The service was authorized with DOCUMENTS_READONLY_SCOPE and can't change documents.
+	ErrReadOnly = errors.New("Read-only access")
+)
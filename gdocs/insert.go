@@ -0,0 +1,614 @@
+package gdocs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type InsertOptions struct {
+	// Insert an empty paragraph before the table, to keep it apart from the previous content.
+	Separator bool
+	// Text of a HEADING_2 paragraph naming the section of the table, usually the heading preceding it on the page.
+	// It goes above Heading, empty inserts none.
+	Section string
+	// Text of a HEADING_3 paragraph inserted above the table, empty inserts none.
+	Heading string
+	// Text of an italic paragraph inserted right above the table, below Heading, empty inserts none.
+	Caption string
+	// Fixed widths of the first columns in points, the columns without a width keep the default one.
+	ColumnWidths []float64
+	// Distribute the table width evenly between the columns, ColumnWidths is ignored then.
+	EqualColumnWidths bool
+	// Reject a table whose rows have different numbers of cells instead of padding the short rows with empty cells.
+	StrictColumns bool
+	// Background of every other row, nil leaves the rows as they are.
+	StripeColor *docs.RgbColor
+	// Bold the first row even if it isn't made of th cells, th rows are always bold.
+	BoldHeader bool
+	// Borders of the cells, nil leaves the default ones.
+	Borders *Borders
+	// Text of a normal paragraph above Section, such as when the tables were written, empty inserts none.
+	// It is left out of the hash of the tables, so that a new timestamp alone isn't a change.
+	Preamble string `json:"-"`
+	// Progress messages, slog.Default() if nil.
+	Logger *slog.Logger `json:"-"`
+}
+
+// The logger of the progress messages, Logger or slog.Default().
+func (opts InsertOptions) logger() *slog.Logger {
+	if opts.Logger == nil {
+		return slog.Default()
+	}
+	return opts.Logger
+}
+
+// Docs API indices count UTF-16 code units, so characters outside the BMP, such as most emoji, take 2.
+func utf16Length(s string) int64 {
+	length := int64(0)
+	for _, r := range s {
+		if r >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+// Converts a rune offset into text into a Docs index offset.
+func utf16Offset(text string, runeOffset int) int64 {
+	return utf16Length(string([]rune(text)[:runeOffset]))
+}
+
+// Styles the spans of a cell whose text starts at textStart in the document.
+func spanStyleRequests(cell scrape.Cell, textStart int64) []*docs.Request {
+	requests := []*docs.Request{}
+	for _, span := range cell.Spans {
+		style := &docs.TextStyle{}
+		fields := []string{}
+		if span.Link != "" {
+			style.Link = &docs.Link{Url: span.Link}
+			fields = append(fields, "link")
+		}
+		if span.Bold {
+			style.Bold = true
+			fields = append(fields, "bold")
+		}
+		if span.Italic {
+			style.Italic = true
+			fields = append(fields, "italic")
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		requests = append(requests, &docs.Request{
+			UpdateTextStyle: &docs.UpdateTextStyleRequest{
+				TextStyle: style,
+				Fields:    strings.Join(fields, ","),
+				Range: &docs.Range{
+					StartIndex: textStart + utf16Offset(cell.Text, span.Start),
+					EndIndex:   textStart + utf16Offset(cell.Text, span.End),
+				},
+			},
+		})
+	}
+	return requests
+}
+
+// Turns the List spans of a cell whose text starts at textStart into bulleted or numbered lists.
+// Docs takes the nesting level from the leading tabs and removes them, which moves the text after them,
+// so these requests go after all the others, last in the document first.
+func bulletRequests(cell scrape.Cell, textStart int64) []*docs.Request {
+	requests := []*docs.Request{}
+	for _, span := range cell.Spans {
+		if !span.List {
+			continue
+		}
+
+		preset := "BULLET_DISC_CIRCLE_SQUARE"
+		if span.Ordered {
+			preset = "NUMBERED_DECIMAL_ALPHA_ROMAN"
+		}
+		requests = append(requests, &docs.Request{
+			CreateParagraphBullets: &docs.CreateParagraphBulletsRequest{
+				BulletPreset: preset,
+				Range: &docs.Range{
+					StartIndex: textStart + utf16Offset(cell.Text, span.Start),
+					EndIndex:   textStart + utf16Offset(cell.Text, span.End),
+				},
+			},
+		})
+	}
+	return requests
+}
+
+// Stands for an image in the inserted text until the image replaces it.
+const IMAGE_PLACEHOLDER = '\ufffc'
+
+// Replaces the text of every Image span, the alt text, with a single IMAGE_PLACEHOLDER and moves the other
+// spans accordingly. Swapping the placeholder for the image keeps the length, so the indices of the other
+// requests stay right whenever the images are inserted.
+func withImagePlaceholders(cell scrape.Cell) scrape.Cell {
+	images := []scrape.Span{}
+	for _, span := range cell.Spans {
+		if span.Image != "" {
+			images = append(images, span)
+		}
+	}
+	if len(images) == 0 {
+		return cell
+	}
+
+	runes := []rune(cell.Text)
+	// The new offset of every old rune offset, the runes of the alt text map to their placeholder.
+	offsets := make([]int, len(runes)+1)
+	out := []rune{}
+	placeholders := map[int]int{}
+	next := 0
+	for i := 0; i <= len(runes); {
+		offsets[i] = len(out)
+		// An image without alt text takes no runes, its placeholder goes right here.
+		for next < len(images) && images[next].End <= i {
+			placeholders[next] = len(out)
+			out = append(out, IMAGE_PLACEHOLDER)
+			next++
+		}
+
+		if next < len(images) && images[next].Start <= i {
+			placeholders[next] = len(out)
+			out = append(out, IMAGE_PLACEHOLDER)
+			for j := i + 1; j < images[next].End; j++ {
+				offsets[j] = placeholders[next]
+			}
+			i = images[next].End
+			next++
+			continue
+		}
+
+		if i < len(runes) {
+			out = append(out, runes[i])
+		}
+		i++
+	}
+
+	placeheld := scrape.Cell{Text: string(out), Spans: []scrape.Span{}, Align: cell.Align}
+	imageIdx := 0
+	for _, span := range cell.Spans {
+		if span.Image != "" {
+			span.Start = placeholders[imageIdx]
+			span.End = span.Start + 1
+			imageIdx++
+		} else {
+			span.Start, span.End = offsets[span.Start], offsets[span.End]
+		}
+		placeheld.Spans = append(placeheld.Spans, span)
+	}
+	return placeheld
+}
+
+// Swaps the placeholders of a cell whose text starts at textStart for the images, see withImagePlaceholders.
+// Google Docs fetches the images itself and fails the whole batch if it can't, see scrape.CheckImages.
+func imageRequests(cell scrape.Cell, textStart int64) []*docs.Request {
+	requests := []*docs.Request{}
+	for _, span := range cell.Spans {
+		if span.Image == "" {
+			continue
+		}
+
+		index := textStart + utf16Offset(cell.Text, span.Start)
+		requests = append(requests, &docs.Request{
+			InsertInlineImage: &docs.InsertInlineImageRequest{
+				Uri:      span.Image,
+				Location: &docs.Location{Index: index},
+			},
+		}, &docs.Request{
+			DeleteContentRange: &docs.DeleteContentRangeRequest{
+				Range: &docs.Range{StartIndex: index + 1, EndIndex: index + 2},
+			},
+		})
+	}
+	return requests
+}
+
+// Reverses requests in place, so that the requests near the end of the document go first.
+func reverseRequests(requests []*docs.Request) []*docs.Request {
+	for i, j := 0, len(requests)-1; i < j; i, j = i+1, j-1 {
+		requests[i], requests[j] = requests[j], requests[i]
+	}
+	return requests
+}
+
+func columnWidthRequests(tableStart *docs.Location, colCnt int, opts InsertOptions) []*docs.Request {
+	if opts.EqualColumnWidths {
+		return []*docs.Request{{
+			UpdateTableColumnProperties: &docs.UpdateTableColumnPropertiesRequest{
+				TableStartLocation:    tableStart,
+				TableColumnProperties: &docs.TableColumnProperties{WidthType: "EVENLY_DISTRIBUTED"},
+				// No column indices means all the columns.
+				Fields: "widthType",
+			},
+		}}
+	}
+
+	requests := []*docs.Request{}
+	for col, width := range opts.ColumnWidths {
+		if col >= colCnt {
+			break
+		}
+
+		requests = append(requests, &docs.Request{
+			UpdateTableColumnProperties: &docs.UpdateTableColumnPropertiesRequest{
+				TableStartLocation: tableStart,
+				ColumnIndices:      []int64{int64(col)},
+				TableColumnProperties: &docs.TableColumnProperties{
+					WidthType: "FIXED_WIDTH",
+					Width:     &docs.Dimension{Magnitude: width, Unit: "PT"},
+				},
+				Fields: "widthType,width",
+			},
+		})
+	}
+	return requests
+}
+
+// A heading paragraph inserted above the table.
+type headingParagraph struct {
+	text           string
+	namedStyleType string
+	italic         bool
+}
+
+// Returns the non-empty preamble, headings and caption in document order. Line breaks would split a heading into several paragraphs,
+// so the whitespace of the text is collapsed.
+func (opts InsertOptions) headingParagraphs() []headingParagraph {
+	paragraphs := []headingParagraph{}
+	if preamble := strings.Join(strings.Fields(opts.Preamble), " "); preamble != "" {
+		paragraphs = append(paragraphs, headingParagraph{preamble, "NORMAL_TEXT", false})
+	}
+	if section := strings.Join(strings.Fields(opts.Section), " "); section != "" {
+		paragraphs = append(paragraphs, headingParagraph{section, "HEADING_2", false})
+	}
+	if heading := strings.Join(strings.Fields(opts.Heading), " "); heading != "" {
+		paragraphs = append(paragraphs, headingParagraph{heading, "HEADING_3", false})
+	}
+	if caption := strings.Join(strings.Fields(opts.Caption), " "); caption != "" {
+		paragraphs = append(paragraphs, headingParagraph{caption, "NORMAL_TEXT", true})
+	}
+	return paragraphs
+}
+
+// Pads the rows of tbl with empty cells up to the longest row, for example when a merged header makes
+// the first row shorter. The rows of tbl are left as they are.
+func padTable(tbl scrape.Table) scrape.Table {
+	colCnt := 0
+	for _, row := range tbl.Contents {
+		if len(row.Cells) > colCnt {
+			colCnt = len(row.Cells)
+		}
+	}
+
+	padded := tbl
+	padded.Contents = make([]scrape.Row, len(tbl.Contents))
+	for i, row := range tbl.Contents {
+		padded.Contents[i] = row
+		if len(row.Cells) < colCnt {
+			cells := make([]scrape.Cell, colCnt)
+			copy(cells, row.Cells)
+			padded.Contents[i].Cells = cells
+		}
+	}
+	return padded
+}
+
+func validateTable(tbl scrape.Table) error {
+	rowCnt := len(tbl.Contents)
+	if rowCnt == 0 {
+		return fmt.Errorf("%w: it has no rows", ErrMalformedTable)
+	}
+
+	colCnt := len(tbl.Contents[0].Cells)
+	for i := 0; i < rowCnt; i++ {
+		if len(tbl.Contents[i].Cells) != colCnt {
+			return fmt.Errorf("%w: %v cells in first row, %v cell in row #%v", ErrMalformedTable, colCnt, len(tbl.Contents[i].Cells), i+1)
+		}
+	}
+
+	return nil
+}
+
+// Pads tbl unless opts asks for strict columns, then checks it can be inserted.
+func prepareTable(tbl scrape.Table, opts InsertOptions) (scrape.Table, error) {
+	if !opts.StrictColumns {
+		tbl = padTable(tbl)
+	}
+	return tbl, validateTable(tbl)
+}
+
+// Creates the empty table at the end of the document, preceded by the optional separator and headings.
+// Text inserted at the end of the segment goes before the body's final newline, so the separator
+// newline splits off a blank paragraph and the last heading ends up in the last paragraph, right above the table.
+func createTableRequests(tbl scrape.Table, opts InsertOptions) []*docs.Request {
+	requests := []*docs.Request{}
+	if opts.Separator {
+		requests = append(requests, &docs.Request{
+			InsertText: &docs.InsertTextRequest{
+				Text:                 "\n",
+				EndOfSegmentLocation: &docs.EndOfSegmentLocation{},
+			},
+		})
+	}
+	if paragraphs := opts.headingParagraphs(); len(paragraphs) > 0 {
+		texts := make([]string, len(paragraphs))
+		for i, paragraph := range paragraphs {
+			texts[i] = paragraph.text
+		}
+
+		requests = append(requests, &docs.Request{
+			InsertText: &docs.InsertTextRequest{
+				Text:                 strings.Join(texts, "\n"),
+				EndOfSegmentLocation: &docs.EndOfSegmentLocation{},
+			},
+		})
+	}
+
+	return append(requests, &docs.Request{
+		InsertTable: &docs.InsertTableRequest{
+			Rows:                 int64(len(tbl.Contents)),
+			Columns:              int64(len(tbl.Contents[0].Cells)),
+			EndOfSegmentLocation: &docs.EndOfSegmentLocation{},
+		},
+	})
+}
+
+// Fills the empty table at doc.Body.Content[tableIdx] with the text of tbl. totalInserted is the length of
+// the text inserted earlier in the same batch, before this table, and is advanced by the text of this table.
+// Insert requests are applied in order and every request inserts after the previous ones, so the ranges
+// of the returned style requests are already the final ones and the styles can go after all the text.
+// The image requests are among the styles, they don't move any text. The bullet requests go after the styles,
+// see bulletRequests.
+func fillTableRequests(doc *docs.Document, tableIdx int, tbl scrape.Table, opts InsertOptions, totalInserted *int64) (requests []*docs.Request, styleRequests []*docs.Request, listRequests []*docs.Request) {
+	paragraphs := opts.headingParagraphs()
+	for i, paragraph := range paragraphs {
+		headingIdx := tableIdx - len(paragraphs) + i
+		if headingIdx < 0 || doc.Body.Content[headingIdx].Paragraph == nil {
+			continue
+		}
+
+		heading := doc.Body.Content[headingIdx]
+		styleRequests = append(styleRequests, &docs.Request{
+			UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+				ParagraphStyle: &docs.ParagraphStyle{NamedStyleType: paragraph.namedStyleType},
+				Fields:         "namedStyleType",
+				Range: &docs.Range{
+					StartIndex: heading.StartIndex + *totalInserted,
+					EndIndex:   heading.EndIndex + *totalInserted,
+				},
+			},
+		})
+
+		if paragraph.italic {
+			styleRequests = append(styleRequests, &docs.Request{
+				UpdateTextStyle: &docs.UpdateTextStyleRequest{
+					TextStyle: &docs.TextStyle{Italic: true},
+					Fields:    "italic",
+					// The newline ending the paragraph is left as it is.
+					Range: &docs.Range{
+						StartIndex: heading.StartIndex + *totalInserted,
+						EndIndex:   heading.EndIndex - 1 + *totalInserted,
+					},
+				},
+			})
+		}
+	}
+
+	tableStart := &docs.Location{Index: doc.Body.Content[tableIdx].StartIndex + *totalInserted}
+	styleRequests = append(styleRequests, columnWidthRequests(tableStart, len(tbl.Contents[0].Cells), opts)...)
+	if opts.StripeColor != nil {
+		styleRequests = append(styleRequests, stripeRequests(tableStart, tbl, opts.StripeColor)...)
+	}
+	if opts.Borders != nil {
+		table := doc.Body.Content[tableIdx].Table
+		styleRequests = append(styleRequests, borderRequests(tableStart, table.Rows, table.Columns, *opts.Borders)...)
+	}
+
+	for rowIdx, row := range doc.Body.Content[tableIdx].Table.TableRows {
+		if row != nil {
+			for cellIdx, cell := range row.TableCells {
+				if cell != nil {
+					cellContent := withImagePlaceholders(tbl.Contents[rowIdx].Cells[cellIdx])
+					text := cellContent.Text
+					textStart := cell.StartIndex + 1 + *totalInserted
+					textLength := utf16Length(text)
+					if textLength == 0 {
+						continue
+					}
+
+					slog.Debug("Inserting cell text", "row", rowIdx, "col", cellIdx, "index", textStart, "length", textLength)
+					requests = append(requests, &docs.Request{
+						InsertText: &docs.InsertTextRequest{
+							Text:     text,
+							Location: &docs.Location{Index: textStart},
+						},
+					})
+
+					if tbl.Contents[rowIdx].Header || (opts.BoldHeader && rowIdx == 0) {
+						styleRequests = append(styleRequests, &docs.Request{
+							UpdateTextStyle: &docs.UpdateTextStyleRequest{
+								TextStyle: &docs.TextStyle{Bold: true},
+								Fields:    "bold",
+								Range: &docs.Range{
+									StartIndex: textStart,
+									EndIndex:   textStart + textLength,
+								},
+							},
+						})
+					}
+
+					if cellContent.Align != "" {
+						styleRequests = append(styleRequests, &docs.Request{
+							UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+								ParagraphStyle: &docs.ParagraphStyle{Alignment: cellContent.Align},
+								Fields:         "alignment",
+								// Every paragraph of the cell, a multi-line cell has several.
+								Range: &docs.Range{
+									StartIndex: textStart,
+									EndIndex:   textStart + textLength,
+								},
+							},
+						})
+					}
+
+					styleRequests = append(styleRequests, spanStyleRequests(cellContent, textStart)...)
+					styleRequests = append(styleRequests, imageRequests(cellContent, textStart)...)
+					listRequests = append(listRequests, bulletRequests(cellContent, textStart)...)
+
+					*totalInserted += textLength
+				}
+			}
+		}
+	}
+
+	return requests, styleRequests, listRequests
+}
+
+// Appends the tables to the end of the document, opts[i] applies to tables[i]. It takes 3 API calls
+// no matter how many tables there are: a BatchUpdate creating all the empty tables, a Get to learn their
+// cell indices and a BatchUpdate filling in all the text, where inserting tables one by one takes 3 calls per table.
+// Invalid tables are skipped and reported in the returned error, the valid ones are still inserted.
+// If filling in the text fails, the empty tables are deleted again, see rollbackCreatedTables.
+// Returns the number of inserted tables.
+func InsertTablesToDocument(ctx context.Context, docId string, srv *docs.Service, tables []scrape.Table, opts []InsertOptions) (int, error) {
+	errs := []error{}
+	valid := []int{}
+	validOpts := []InsertOptions{}
+	createRequests := []*docs.Request{}
+	tables = append([]scrape.Table(nil), tables...)
+	for i := range tables {
+		tbl, err := prepareTable(tables[i], opts[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Table #%v: %w", i, err))
+			continue
+		}
+		tables[i] = tbl
+
+		tableOpts := opts[i]
+		// When the first tables are skipped, the first inserted table takes their place, separator and preamble included.
+		if len(valid) == 0 && i > 0 {
+			tableOpts.Separator = opts[0].Separator
+			tableOpts.Preamble = opts[0].Preamble
+		}
+
+		tableOpts.logger().Info("Inserting table", "table", i+1, "tables", len(tables), "rows", len(tbl.Contents), "cols", len(tbl.Contents[0].Cells))
+		valid = append(valid, i)
+		validOpts = append(validOpts, tableOpts)
+		createRequests = append(createRequests, createTableRequests(tbl, tableOpts)...)
+	}
+
+	if len(valid) == 0 {
+		return 0, errors.Join(errs...)
+	}
+
+	// Without a known revision a retry after an applied but failed request appends the tables twice,
+	// which is no worse than the half-written document a failure would leave anyway.
+	_, err := batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		Requests: createRequests,
+	})
+	if err != nil {
+		return 0, errors.Join(append(errs, err)...)
+	}
+
+	doc, err := srv.Documents.Get(docId).Context(ctx).Do()
+	if err != nil {
+		return 0, errors.Join(append(errs, err)...)
+	}
+
+	tableIndices := []int{}
+	for i, element := range doc.Body.Content {
+		if element.Table != nil {
+			tableIndices = append(tableIndices, i)
+		}
+	}
+
+	if len(tableIndices) < len(valid) {
+		return 0, errors.Join(append(errs, fmt.Errorf("Failed to find the %v inserted tables in doc.Body.Content", len(valid)))...)
+	}
+
+	// The inserted tables are the last ones in the document.
+	tableIndices = tableIndices[len(tableIndices)-len(valid):]
+
+	requests := []*docs.Request{}
+	styleRequests := []*docs.Request{}
+	listRequests := []*docs.Request{}
+	totalInserted := int64(0)
+	for i, tblIdx := range valid {
+		textRequests, tableStyleRequests, tableListRequests := fillTableRequests(doc, tableIndices[i], tables[tblIdx], validOpts[i], &totalInserted)
+		requests = append(requests, textRequests...)
+		styleRequests = append(styleRequests, tableStyleRequests...)
+		listRequests = append(listRequests, tableListRequests...)
+	}
+
+	requests = append(requests, styleRequests...)
+	requests = append(requests, reverseRequests(listRequests)...)
+	if len(requests) == 0 {
+		countInsertedTables(tables, valid)
+		return len(valid), errors.Join(errs...)
+	}
+
+	// The empty tables are already in the document, but a cancelled run shouldn't keep writing.
+	if ctx.Err() != nil {
+		err := fmt.Errorf("Insertion cancelled: %w", ctx.Err())
+		return 0, errors.Join(append(errs, err, rollbackCreatedTables(ctx, srv, doc, tableIndices, validOpts[0]))...)
+	}
+
+	_, err = batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests:     requests,
+	})
+	if err != nil {
+		return 0, errors.Join(append(errs, err, rollbackCreatedTables(ctx, srv, doc, tableIndices, validOpts[0]))...)
+	}
+
+	countInsertedTables(tables, valid)
+	return len(valid), errors.Join(errs...)
+}
+
+const ROLLBACK_TIMEOUT = 30 * time.Second
+
+// Deletes the empty tables created at doc.Body.Content[tableIndices] and the paragraphs above the first one,
+// firstOpts being its options, so that a failed insertion doesn't leave empty tables behind. It runs even
+// when ctx is cancelled. The rollback can fail too, then the empty tables stay and the returned error says so.
+// It requires the revision of doc, so it never deletes tables a lost but applied fill request has filled in.
+func rollbackCreatedTables(ctx context.Context, srv *docs.Service, doc *docs.Document, tableIndices []int, firstOpts InsertOptions) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ROLLBACK_TIMEOUT)
+	defer cancel()
+
+	// Index 0 is the section break that starts the body.
+	first := max(tableIndices[0]-len(firstOpts.headingParagraphs()), 1)
+	deleteRange := &docs.Range{
+		StartIndex: doc.Body.Content[first].StartIndex,
+		EndIndex:   doc.Body.Content[tableIndices[len(tableIndices)-1]].EndIndex,
+	}
+
+	_, err := batchUpdate(ctx, srv, doc.DocumentId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests:     []*docs.Request{{DeleteContentRange: &docs.DeleteContentRangeRequest{Range: deleteRange}}},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to delete the empty tables again, they are left in the document: %w", err)
+	}
+
+	firstOpts.logger().Info("Deleted the empty tables after the failed insertion", "tables", len(tableIndices))
+	return nil
+}
+
+func InsertTableToDocument(ctx context.Context, docId string, srv *docs.Service, tbl scrape.Table, opts InsertOptions) error {
+	_, err := InsertTablesToDocument(ctx, docId, srv, []scrape.Table{tbl}, []InsertOptions{opts})
+	return err
+}
@@ -0,0 +1,437 @@
+package gdocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/internal/docstest"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestUtf16Length(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Жук", 3},
+		{"😀", 2},
+		{"a😀b", 4},
+		// A family emoji is 4 people joined by 3 zero width joiners.
+		{"👨‍👩‍👧‍👦", 11},
+		// e and a combining acute accent.
+		{"e\u0301", 2},
+	}
+
+	for _, test := range tests {
+		if got := utf16Length(test.in); got != test.want {
+			t.Errorf("utf16Length(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestUtf16Offset(t *testing.T) {
+	tests := []struct {
+		text       string
+		runeOffset int
+		want       int64
+	}{
+		{"abc", 2, 2},
+		{"😀abc", 1, 2},
+		{"a😀b😀c", 4, 6},
+		{"Жук", 3, 3},
+	}
+
+	for _, test := range tests {
+		if got := utf16Offset(test.text, test.runeOffset); got != test.want {
+			t.Errorf("utf16Offset(%q, %v) = %v, want %v", test.text, test.runeOffset, got, test.want)
+		}
+	}
+}
+
+func TestFillTableRequests(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	// After the section break and the empty paragraph, the table starts at 2, its row at 3 and its cells
+	// at 4 and 6, the cell paragraphs at 5 and 7.
+	docId := fake.AddDocument("Tables", docstest.Element{Text: "\n"}, docstest.Element{Cells: [][]string{{"\n", "\n"}}})
+	doc, err := srv.Documents.Get(docId).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := scrape.NewRow("😀a", "b")
+	header.Header = true
+	tbl := scrape.Table{Contents: []scrape.Row{header}}
+
+	tests := []struct {
+		name          string
+		totalInserted int64
+		// Index and length of the text inserted into each cell.
+		want [][2]int64
+	}{
+		// The emoji takes 2 indices, so the second cell moves by 3.
+		{"first table", 0, [][2]int64{{5, 3}, {7 + 3, 1}}},
+		{"after other text", 10, [][2]int64{{15, 3}, {20, 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			totalInserted := test.totalInserted
+			requests, styleRequests, _ := fillTableRequests(doc, 2, tbl, InsertOptions{}, &totalInserted)
+			if len(requests) != len(test.want) {
+				t.Fatalf("fillTableRequests() returned %v requests, want %v", len(requests), len(test.want))
+			}
+			for i, request := range requests {
+				if got := request.InsertText.Location.Index; got != test.want[i][0] {
+					t.Errorf("cell %v is inserted at %v, want %v", i, got, test.want[i][0])
+				}
+				bold := styleRequests[i].UpdateTextStyle.Range
+				if bold.StartIndex != test.want[i][0] || bold.EndIndex != test.want[i][0]+test.want[i][1] {
+					t.Errorf("cell %v is bold in [%v, %v), want [%v, %v)", i, bold.StartIndex, bold.EndIndex, test.want[i][0], test.want[i][0]+test.want[i][1])
+				}
+			}
+			if totalInserted != test.totalInserted+4 {
+				t.Errorf("totalInserted = %v, want %v", totalInserted, test.totalInserted+4)
+			}
+		})
+	}
+}
+
+func TestInsertTablesToDocument(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Tables")
+
+	tables := []scrape.Table{
+		{Contents: []scrape.Row{scrape.NewRow("😀 first", "👨‍👩‍👧‍👦"), scrape.NewRow("after", "Жук")}},
+		{Contents: []scrape.Row{scrape.NewRow("second 😀")}},
+	}
+	opts := []InsertOptions{{Heading: "One 😀"}, {Separator: true, Heading: "Two"}}
+	inserted, err := InsertTablesToDocument(context.Background(), docId, srv, tables, opts)
+	if err != nil || inserted != 2 {
+		t.Fatalf("InsertTablesToDocument() = %v, %v, want 2 tables", inserted, err)
+	}
+
+	want := [][]string{{"😀 first\n", "👨‍👩‍👧‍👦\n"}, {"after\n", "Жук\n"}, {"second 😀\n"}}
+	got := [][]string{}
+	headings := []string{}
+	for _, element := range fake.Document(docId).Elements {
+		got = append(got, element.Cells...)
+		if element.Style == "HEADING_3" {
+			headings = append(headings, element.Text)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("cells = %q, want %q", got, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Errorf("cell %v,%v = %q, want %q", i, j, got[i][j], want[i][j])
+			}
+		}
+	}
+	if len(headings) != 2 || headings[0] != "One 😀\n" || headings[1] != "Two\n" {
+		t.Errorf("headings = %q, want the two table headings", headings)
+	}
+}
+
+// Returns the rows of a two-row table whose cell text fillTableRequests bolds.
+func boldRows(t *testing.T, header []bool, opts InsertOptions) []int {
+	t.Helper()
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Tables", docstest.Element{Text: "\n"}, docstest.Element{Cells: [][]string{{"\n"}, {"\n"}}})
+	doc, err := srv.Documents.Get(docId).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := scrape.Table{Contents: []scrape.Row{scrape.NewRow("a"), scrape.NewRow("b")}}
+	for i := range header {
+		tbl.Contents[i].Header = header[i]
+	}
+	totalInserted := int64(0)
+	_, styleRequests, _ := fillTableRequests(doc, 2, tbl, opts, &totalInserted)
+
+	// The text of the cells starts at 5 and, after the "a" of the first one, at 9.
+	rows := []int{}
+	for _, request := range styleRequests {
+		if request.UpdateTextStyle != nil && request.UpdateTextStyle.TextStyle.Bold {
+			row, ok := map[int64]int{5: 0, 9: 1}[request.UpdateTextStyle.Range.StartIndex]
+			if !ok {
+				t.Errorf("bold range %+v isn't the text of a cell", request.UpdateTextStyle.Range)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestHeaderRowsBold(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []bool
+		opts   InsertOptions
+		want   []int
+	}{
+		{"th row", []bool{true, false}, InsertOptions{}, []int{0}},
+		{"no header", []bool{false, false}, InsertOptions{}, []int{}},
+		{"two th rows", []bool{true, true}, InsertOptions{}, []int{0, 1}},
+		{"bold header", []bool{false, false}, InsertOptions{BoldHeader: true}, []int{0}},
+		{"bold header and th row", []bool{true, false}, InsertOptions{BoldHeader: true}, []int{0}},
+		{"bold header and two th rows", []bool{true, true}, InsertOptions{BoldHeader: true}, []int{0, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := boldRows(t, test.header, test.opts)
+			if len(got) != len(test.want) {
+				t.Fatalf("bold rows = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("bold rows = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareTable(t *testing.T) {
+	ragged := scrape.Table{Contents: []scrape.Row{scrape.NewRow("merged"), scrape.NewRow("a", "b", "c"), scrape.NewRow("d", "e")}}
+	tests := []struct {
+		name   string
+		tbl    scrape.Table
+		strict bool
+		want   []int
+		err    string
+	}{
+		{"padded", ragged, false, []int{3, 3, 3}, ""},
+		{"strict", ragged, true, nil, "1 cells in first row, 3 cell in row #2"},
+		{"rectangular strict", scrape.Table{Contents: []scrape.Row{scrape.NewRow("a", "b"), scrape.NewRow("c", "d")}}, true, []int{2, 2}, ""},
+		{"no rows", scrape.Table{}, false, nil, "it has no rows"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := prepareTable(test.tbl, InsertOptions{StrictColumns: test.strict})
+			if test.err != "" {
+				if !errors.Is(err, ErrMalformedTable) || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("prepareTable() = %v, want ErrMalformedTable with %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, row := range got.Contents {
+				if len(row.Cells) != test.want[i] {
+					t.Errorf("row %v has %v cells, want %v", i, len(row.Cells), test.want[i])
+				}
+			}
+		})
+	}
+
+	// The rows of the scraped table are left as they are.
+	if len(ragged.Contents[0].Cells) != 1 || ragged.Contents[0].Cells[0].Text != "merged" {
+		t.Errorf("padTable() modified the rows of its argument: %+v", ragged.Contents[0])
+	}
+}
+
+func TestInsertRaggedTables(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Tables")
+
+	ragged := scrape.Table{Contents: []scrape.Row{scrape.NewRow("merged"), scrape.NewRow("a", "b")}}
+	tables := []scrape.Table{ragged, ragged}
+	opts := []InsertOptions{{StrictColumns: true}, {}}
+	inserted, err := InsertTablesToDocument(context.Background(), docId, srv, tables, opts)
+	// The strict table is skipped and reported, the padded one is still inserted.
+	if inserted != 1 || !errors.Is(err, ErrMalformedTable) {
+		t.Fatalf("InsertTablesToDocument() = %v, %v, want 1 table and ErrMalformedTable", inserted, err)
+	}
+
+	got := [][]string{}
+	for _, element := range fake.Document(docId).Elements {
+		got = append(got, element.Cells...)
+	}
+	if len(got) != 2 || len(got[0]) != 2 || got[0][0] != "merged\n" || got[0][1] != "\n" || got[1][1] != "b\n" {
+		t.Errorf("cells = %q, want the padded table", got)
+	}
+}
+
+func TestBulletRequests(t *testing.T) {
+	cell := scrape.Cell{
+		Text: "😀 Intro\none\n\tsub\nafter\nfirst",
+		Spans: []scrape.Span{
+			{Start: 0, End: 1, Bold: true},
+			{Start: 8, End: 16, List: true},
+			{Start: 23, End: 28, List: true, Ordered: true},
+		},
+	}
+	requests := bulletRequests(cell, 10)
+
+	// The emoji before the lists takes 2 indices.
+	want := []struct {
+		preset     string
+		start, end int64
+	}{
+		{"BULLET_DISC_CIRCLE_SQUARE", 19, 27},
+		{"NUMBERED_DECIMAL_ALPHA_ROMAN", 34, 39},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("bulletRequests() returned %v requests, want %v", len(requests), len(want))
+	}
+	for i, request := range requests {
+		bullets := request.CreateParagraphBullets
+		if bullets.BulletPreset != want[i].preset || bullets.Range.StartIndex != want[i].start || bullets.Range.EndIndex != want[i].end {
+			t.Errorf("request %v = %v [%v, %v), want %v [%v, %v)", i, bullets.BulletPreset, bullets.Range.StartIndex, bullets.Range.EndIndex, want[i].preset, want[i].start, want[i].end)
+		}
+	}
+}
+
+func TestHeadingParagraphs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts InsertOptions
+		want []headingParagraph
+	}{
+		{"none", InsertOptions{}, []headingParagraph{}},
+		{"caption", InsertOptions{Caption: "All users"}, []headingParagraph{{"All users", "NORMAL_TEXT", true}}},
+		{
+			"all",
+			InsertOptions{Preamble: "Updated", Section: "Users", Heading: "Table 1", Caption: "All\nusers"},
+			[]headingParagraph{{"Updated", "NORMAL_TEXT", false}, {"Users", "HEADING_2", false}, {"Table 1", "HEADING_3", false}, {"All users", "NORMAL_TEXT", true}},
+		},
+		{"blank", InsertOptions{Section: " \n ", Caption: "\t"}, []headingParagraph{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.opts.headingParagraphs()
+			if len(got) != len(test.want) {
+				t.Fatalf("headingParagraphs() = %+v, want %+v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("paragraph %v = %+v, want %+v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInsertCaption(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Tables")
+
+	tbl := scrape.Table{Contents: []scrape.Row{scrape.NewRow("a")}}
+	if _, err := InsertTablesToDocument(context.Background(), docId, srv, []scrape.Table{tbl}, []InsertOptions{{Heading: "Users", Caption: "All users"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The caption goes right above the table, below the heading.
+	elements := fake.Document(docId).Elements
+	tableIdx := -1
+	for i, element := range elements {
+		if element.Cells != nil {
+			tableIdx = i
+		}
+	}
+	if tableIdx < 2 || elements[tableIdx-1].Text != "All users\n" || elements[tableIdx-2].Text != "Users\n" || elements[tableIdx-2].Style != "HEADING_3" {
+		t.Fatalf("document = %+v, want the heading and the caption above the table", elements)
+	}
+
+	italic := false
+	for _, batch := range fake.Batches() {
+		for _, request := range batch.Requests {
+			if style := request.UpdateTextStyle; style != nil && style.TextStyle.Italic {
+				// The caption paragraph without its newline.
+				italic = style.Range.EndIndex-style.Range.StartIndex == utf16Length("All users")
+			}
+		}
+	}
+	if !italic {
+		t.Errorf("the caption isn't italic")
+	}
+}
+
+func TestAlignmentRequests(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Tables", docstest.Element{Text: "\n"}, docstest.Element{Cells: [][]string{{"\n", "\n"}}})
+	doc, err := srv.Documents.Get(docId).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := scrape.Table{Contents: []scrape.Row{{Cells: []scrape.Cell{{Text: "left"}, {Text: "a\nb", Align: scrape.ALIGN_CENTER}}}}}
+	totalInserted := int64(0)
+	_, styleRequests, _ := fillTableRequests(doc, 2, tbl, InsertOptions{}, &totalInserted)
+
+	aligned := []*docs.UpdateParagraphStyleRequest{}
+	for _, request := range styleRequests {
+		if request.UpdateParagraphStyle != nil {
+			aligned = append(aligned, request.UpdateParagraphStyle)
+		}
+	}
+	if len(aligned) != 1 {
+		t.Fatalf("sent %v paragraph style requests, want one for the aligned cell", len(aligned))
+	}
+
+	// The second cell starts at 7, after the "left" of the first one it starts at 11, and the range
+	// covers both of its paragraphs.
+	got := aligned[0]
+	if got.ParagraphStyle.Alignment != scrape.ALIGN_CENTER || got.Fields != "alignment" || got.Range.StartIndex != 11 || got.Range.EndIndex != 14 {
+		t.Errorf("request = %v %v %+v, want CENTER alignment of 11-14", got.ParagraphStyle.Alignment, got.Fields, got.Range)
+	}
+}
+
+// Returns a logger of JSON records, and a func returning the records logged so far.
+func testLogger(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	b := &bytes.Buffer{}
+	records := func() []map[string]any {
+		all := []map[string]any{}
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			record := map[string]any{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("log line %q: %v", line, err)
+			}
+			all = append(all, record)
+		}
+		return all
+	}
+	return slog.New(slog.NewJSONHandler(b, nil)), records
+}
+
+func TestInsertProgressLog(t *testing.T) {
+	fake := docstest.NewServer(t)
+	docId := fake.AddDocument("Tables")
+	logger, records := testLogger(t)
+
+	tables := []scrape.Table{{Contents: []scrape.Row{scrape.NewRow("a", "b"), scrape.NewRow("c", "d")}}}
+	if _, err := InsertTablesToDocument(context.Background(), docId, fake.Service(t), tables, []InsertOptions{{Logger: logger}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := records()
+	if len(got) != 1 {
+		t.Fatalf("logged %v, want a record per table", got)
+	}
+	want := map[string]any{"msg": "Inserting table", "table": 1.0, "tables": 1.0, "rows": 2.0, "cols": 2.0}
+	for key, value := range want {
+		if got[0][key] != value {
+			t.Errorf("%v = %v, want %v", key, got[0][key], value)
+		}
+	}
+}
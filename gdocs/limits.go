@@ -0,0 +1,55 @@
+package gdocs
+
+import (
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/scrape"
+	"log/slog"
+)
+
+// A Google Docs document holds at most 1.02 million characters.
+const DOCS_MAX_CHARACTERS = 1_020_000
+
+// Not a documented limit, but filling a table with more cells than this takes requests the API rejects or times out on.
+const DOCS_MAX_TABLE_CELLS = 50_000
+
+// Warn about tables above this share of a limit, they are likely to hit it as the page grows.
+const DOCS_LIMIT_WARN_RATIO = 0.8
+
+// Estimates the cells of every table and the characters of the document after inserting tables,
+// and fails with ErrTooLarge if they go over the limits of Google Docs, instead of leaving that to
+// an opaque API error halfway through writing. existing is the document whose content is kept,
+// nil if it is cleared. Tables close to a limit are only logged.
+func CheckSize(tables []scrape.Table, existing *docs.Document) error {
+	chars := int64(0)
+	if existing != nil && existing.Body != nil && len(existing.Body.Content) > 0 {
+		chars = existing.Body.Content[len(existing.Body.Content)-1].EndIndex
+	}
+
+	for i, tbl := range tables {
+		colCnt := 0
+		for _, row := range tbl.Contents {
+			colCnt = max(colCnt, len(row.Cells))
+			for _, cell := range row.Cells {
+				// Every cell ends with a newline.
+				chars += utf16Length(cell.Text) + 1
+			}
+		}
+
+		cells := len(tbl.Contents) * colCnt
+		if cells > DOCS_MAX_TABLE_CELLS {
+			return fmt.Errorf("%w: table #%v has %v cells, more than the %v Google Docs can take, cut it down to fewer rows or columns", ErrTooLarge, i, cells, DOCS_MAX_TABLE_CELLS)
+		}
+		if float64(cells) > DOCS_LIMIT_WARN_RATIO*DOCS_MAX_TABLE_CELLS {
+			slog.Warn("The table is close to the cell limit of Google Docs", "table", i, "cells", cells, "limit", DOCS_MAX_TABLE_CELLS)
+		}
+	}
+
+	if chars > DOCS_MAX_CHARACTERS {
+		return fmt.Errorf("%w: the document would have about %v characters, more than the %v Google Docs allows, cut the tables down to fewer rows or shorter cells", ErrTooLarge, chars, DOCS_MAX_CHARACTERS)
+	}
+	if float64(chars) > DOCS_LIMIT_WARN_RATIO*DOCS_MAX_CHARACTERS {
+		slog.Warn("The document is close to the character limit of Google Docs", "characters", chars, "limit", DOCS_MAX_CHARACTERS)
+	}
+	return nil
+}
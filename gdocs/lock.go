@@ -0,0 +1,44 @@
+package gdocs
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const LOCK_TIMEOUT = 30 * time.Second
+const LOCK_POLL_INTERVAL = 50 * time.Millisecond
+
+// Takes the lock of path, an advisory lock of the file path plus ".lock", so it works across processes.
+// The system drops the lock of a process that exits, so a crashed instance never leaves a stale one behind.
+// Waits up to LOCK_TIMEOUT for another holder. The returned func releases the lock.
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	// The file stays after unlocking. Removing it would let a waiter lock the removed file while
+	// another instance locks a new one.
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create lock file %v: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(LOCK_TIMEOUT)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Unable to lock %v: %w", lockPath, err)
+		}
+		if locked {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("Timed out waiting for lock file %v, another instance holds it", lockPath)
+		}
+		time.Sleep(LOCK_POLL_INTERVAL)
+	}
+}
@@ -0,0 +1,57 @@
+package gdocs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileWaitsForHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan func())
+	go func() {
+		unlockSecond, err := lockFile(path)
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- unlockSecond
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lockFile() took a held lock")
+	case <-time.After(10 * LOCK_POLL_INTERVAL):
+	}
+
+	unlock()
+	select {
+	case unlockSecond := <-locked:
+		unlockSecond()
+	case <-time.After(LOCK_TIMEOUT / 2):
+		t.Fatal("lockFile() didn't take the released lock")
+	}
+}
+
+// A crashed instance leaves its lock file, but not the lock.
+func TestLockFileLeftBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path+".lock", []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if elapsed := time.Since(start); elapsed > LOCK_TIMEOUT/2 {
+		t.Errorf("lockFile() waited %v for a lock without a holder", elapsed)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package gdocs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Takes an exclusive flock of f without waiting, false if another open file holds it.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
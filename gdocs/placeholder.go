@@ -0,0 +1,138 @@
+package gdocs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/scrape"
+	"strings"
+)
+
+// Returns the document range of the first occurrence of placeholder in the body, outside of tables.
+// The placeholder has to be within a single text run, that is typed with the same style throughout.
+func findPlaceholder(doc *docs.Document, placeholder string) (*docs.Range, bool) {
+	for _, element := range doc.Body.Content {
+		if element.Paragraph == nil {
+			continue
+		}
+
+		for _, paragraphElement := range element.Paragraph.Elements {
+			if paragraphElement.TextRun == nil {
+				continue
+			}
+
+			content := paragraphElement.TextRun.Content
+			if i := strings.Index(content, placeholder); i >= 0 {
+				start := paragraphElement.StartIndex + utf16Length(content[:i])
+				return &docs.Range{StartIndex: start, EndIndex: start + utf16Length(placeholder)}, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the index in doc.Body.Content of the first table starting at or after index.
+func tableAfter(doc *docs.Document, index int64) (int, bool) {
+	for i, element := range doc.Body.Content {
+		if element.Table != nil && element.StartIndex >= index {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Replaces the placeholder with the table, taking a Get, a BatchUpdate replacing the placeholder
+// with an empty table, another Get to learn its cell indices and a BatchUpdate filling it in.
+// Reports false if the document has no placeholder left.
+func replacePlaceholder(ctx context.Context, docId string, srv *docs.Service, placeholder string, tbl scrape.Table, opts InsertOptions) (bool, error) {
+	doc, err := srv.Documents.Get(docId).Context(ctx).Do()
+	if err != nil {
+		return false, err
+	}
+
+	placeholderRange, ok := findPlaceholder(doc, placeholder)
+	if !ok {
+		return false, nil
+	}
+
+	_, err = batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests: []*docs.Request{
+			{DeleteContentRange: &docs.DeleteContentRangeRequest{Range: placeholderRange}},
+			{InsertTable: &docs.InsertTableRequest{
+				Rows:     int64(len(tbl.Contents)),
+				Columns:  int64(len(tbl.Contents[0].Cells)),
+				Location: &docs.Location{Index: placeholderRange.StartIndex},
+			}},
+		},
+	})
+	if err != nil {
+		return true, err
+	}
+
+	doc, err = srv.Documents.Get(docId).Context(ctx).Do()
+	if err != nil {
+		return true, err
+	}
+
+	tableIdx, ok := tableAfter(doc, placeholderRange.StartIndex)
+	if !ok {
+		return true, fmt.Errorf("Failed to find the table inserted at index %v", placeholderRange.StartIndex)
+	}
+
+	totalInserted := int64(0)
+	requests, styleRequests, listRequests := fillTableRequests(doc, tableIdx, tbl, opts, &totalInserted)
+	requests = append(requests, styleRequests...)
+	requests = append(requests, reverseRequests(listRequests)...)
+	if len(requests) == 0 {
+		return true, nil
+	}
+
+	_, err = batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests:     requests,
+	})
+	return true, err
+}
+
+// Replaces the occurrences of placeholder in the document with the tables, the first occurrence with the first
+// table and so on, leaving the rest of the document as it is. Separators, headings and captions are never inserted.
+// Tables without a placeholder left are skipped with a warning. Returns the number of inserted tables.
+func InsertTablesAtPlaceholders(ctx context.Context, docId string, srv *docs.Service, tables []scrape.Table, opts []InsertOptions, placeholder string) (int, error) {
+	errs := []error{}
+	inserted := 0
+	for i := range tables {
+		if ctx.Err() != nil {
+			return inserted, errors.Join(append(errs, fmt.Errorf("Insertion cancelled: %w", ctx.Err()))...)
+		}
+
+		tbl, err := prepareTable(tables[i], opts[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Table #%v: %w", i, err))
+			continue
+		}
+
+		tableOpts := opts[i]
+		tableOpts.Preamble = ""
+		tableOpts.Section = ""
+		tableOpts.Heading = ""
+		tableOpts.Caption = ""
+
+		tableOpts.logger().Info("Inserting table", "table", i+1, "tables", len(tables), "rows", len(tbl.Contents), "cols", len(tbl.Contents[0].Cells), "placeholder", placeholder)
+		found, err := replacePlaceholder(ctx, docId, srv, placeholder, tbl, tableOpts)
+		if !found && err == nil {
+			tableOpts.logger().Warn("No placeholder left in the document, skipping the remaining tables", "placeholder", placeholder, "skipped", len(tables)-i)
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Table #%v: %w", i, err))
+			continue
+		}
+		countInserted(tbl)
+		inserted++
+	}
+
+	return inserted, errors.Join(errs...)
+}
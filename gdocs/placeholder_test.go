@@ -0,0 +1,32 @@
+package gdocs
+
+import (
+	"context"
+	"hflabstesttask/internal/docstest"
+	"hflabstesttask/scrape"
+	"testing"
+)
+
+func TestInsertTablesWithoutPlaceholder(t *testing.T) {
+	fake := docstest.NewServer(t)
+	docId := fake.AddDocument("Template", docstest.Element{Text: "No marker here\n"})
+	logger, records := testLogger(t)
+
+	tables := []scrape.Table{{Contents: []scrape.Row{scrape.NewRow("a")}}, {Contents: []scrape.Row{scrape.NewRow("b")}}}
+	opts := []InsertOptions{{Logger: logger}, {Logger: logger}}
+	inserted, err := InsertTablesAtPlaceholders(context.Background(), docId, fake.Service(t), tables, opts, "{{tables}}")
+	if err != nil || inserted != 0 {
+		t.Fatalf("InsertTablesAtPlaceholders() = %v, %v, want no tables", inserted, err)
+	}
+
+	got := records()
+	if len(got) != 2 || got[0]["msg"] != "Inserting table" || got[0]["placeholder"] != "{{tables}}" || got[0]["table"] != 1.0 {
+		t.Fatalf("logged %v, want the first table with its placeholder", got)
+	}
+	if got[1]["level"] != "WARN" || got[1]["skipped"] != 2.0 {
+		t.Errorf("logged %v, want a warning skipping both tables", got[1])
+	}
+	if len(fake.Batches()) != 0 {
+		t.Errorf("sent %v batch updates to a document without the placeholder", len(fake.Batches()))
+	}
+}
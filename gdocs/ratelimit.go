@@ -0,0 +1,102 @@
+package gdocs
+
+import (
+	"bytes"
+	"golang.org/x/time/rate"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DOCS_RETRY_ATTEMPTS = 5
+const DOCS_INITIAL_BACKOFF = time.Second
+
+// Spaces out the Docs API requests so that a run stays under the per-minute write quota,
+// and retries the requests the API still rejects with a rate limit error.
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+// Rate limit errors are 429, or 403 with a rateLimitExceeded or userRateLimitExceeded reason in the body.
+func isRateLimited(response *http.Response, body []byte) bool {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return bytes.Contains(bytes.ToLower(body), []byte("ratelimitexceeded"))
+	}
+	return false
+}
+
+// Honors the Retry-After seconds of the response, otherwise sleeps somewhere in [backoff/2, 3*backoff/2).
+func retryDelay(response *http.Response, backoff time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+}
+
+func (t *rateLimitedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	backoff := DOCS_INITIAL_BACKOFF
+	for attempt := 1; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		apiCalls.Add(1)
+		response, err := t.base.RoundTrip(request)
+		if err != nil || attempt >= DOCS_RETRY_ATTEMPTS || (response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusForbidden) {
+			return response, err
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		// Without GetBody the request body can't be sent again.
+		if !isRateLimited(response, body) || (request.Body != nil && request.GetBody == nil) {
+			return response, nil
+		}
+
+		sleep := retryDelay(response, backoff)
+		slog.Warn("Docs API rate limit exceeded, retrying", "attempt", attempt, "attempts", DOCS_RETRY_ATTEMPTS, "status", response.Status, "retry_in", sleep)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+
+		if request.GetBody != nil {
+			retry := request.Clone(ctx)
+			if retry.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+			request = retry
+		}
+	}
+}
+
+// Wraps the transport of client with a limiter allowing qps requests per second, qps <= 0 means no limit.
+func rateLimitedClient(client *http.Client, qps float64) *http.Client {
+	limit := rate.Inf
+	if qps > 0 {
+		limit = rate.Limit(qps)
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	limited := *client
+	limited.Transport = &rateLimitedTransport{base: base, limiter: rate.NewLimiter(limit, 1)}
+	return &limited
+}
@@ -0,0 +1,116 @@
+package gdocs
+
+import (
+	"context"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/option"
+	"hflabstesttask/internal/docstest"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsRateLimited(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{http.StatusTooManyRequests, "", true},
+		{http.StatusForbidden, `{"error": {"errors": [{"reason": "userRateLimitExceeded"}]}}`, true},
+		{http.StatusForbidden, `{"error": {"errors": [{"reason": "rateLimitExceeded"}]}}`, true},
+		{http.StatusForbidden, `{"error": {"errors": [{"reason": "forbidden"}]}}`, false},
+		{http.StatusInternalServerError, "rateLimitExceeded", false},
+	}
+
+	for _, test := range tests {
+		if got := isRateLimited(&http.Response{StatusCode: test.status}, []byte(test.body)); got != test.want {
+			t.Errorf("isRateLimited(%v, %q) = %v, want %v", test.status, test.body, got, test.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"", time.Second, 3 * time.Second},
+		{"0", time.Second, 3 * time.Second},
+		// An HTTP date isn't supported, the backoff is used instead.
+		{"Wed, 21 Oct 2015 07:28:00 GMT", time.Second, 3 * time.Second},
+	}
+
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{}}
+		response.Header.Set("Retry-After", test.retryAfter)
+		if got := retryDelay(response, 2*time.Second); got < test.min || got > test.max {
+			t.Errorf("retryDelay(Retry-After %q) = %v, want it in [%v, %v]", test.retryAfter, got, test.min, test.max)
+		}
+	}
+}
+
+func TestRateLimitedRetry(t *testing.T) {
+	fake := docstest.NewServer(t)
+	docId := fake.AddDocument("Tables")
+
+	requests := atomic.Int32{}
+	fake.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if requests.Add(1) > 1 {
+			return false
+		}
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return true
+	}
+
+	srv, err := docs.NewService(context.Background(), option.WithEndpoint(fake.URL+"/"), option.WithHTTPClient(rateLimitedClient(fake.Client(), 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = batchUpdate(context.Background(), srv, docId, &docs.BatchUpdateDocumentRequest{
+		Requests: []*docs.Request{{InsertText: &docs.InsertTextRequest{Text: "Hello", EndOfSegmentLocation: &docs.EndOfSegmentLocation{}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("sent %v requests, want the rate limited one and its retry", requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s of Retry-After", elapsed)
+	}
+	// The retry sends the request body again.
+	if got := fake.Document(docId).Elements; len(got) != 1 || got[0].Text != "Hello\n" {
+		t.Errorf("document = %q, want the text inserted once", got)
+	}
+}
+
+func TestRateLimitedGivesUp(t *testing.T) {
+	fake := docstest.NewServer(t)
+	requests := atomic.Int32{}
+	fake.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"code": 403, "message": "The caller does not have permission", "errors": [{"reason": "forbidden"}]}}`))
+		return true
+	}
+
+	srv, err := docs.NewService(context.Background(), option.WithEndpoint(fake.URL+"/"), option.WithHTTPClient(rateLimitedClient(fake.Client(), 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = srv.Documents.Get("doc").Do()
+	if err == nil || !strings.Contains(err.Error(), "The caller does not have permission") {
+		t.Errorf("Get() = %v, want the permission error", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("sent %v requests, a 403 that isn't a rate limit isn't retried", requests.Load())
+	}
+}
@@ -0,0 +1,45 @@
+package gdocs
+
+import (
+	"context"
+	"google.golang.org/api/docs/v1"
+	"sort"
+)
+
+// Replaces every occurrence of each token of values in the document with its value, case-sensitively,
+// in a single BatchUpdate. Returns the number of occurrences replaced per token.
+func ReplaceAllText(ctx context.Context, docId string, srv *docs.Service, values map[string]string) (map[string]int64, error) {
+	tokens := []string{}
+	for token := range values {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	requests := []*docs.Request{}
+	for _, token := range tokens {
+		requests = append(requests, &docs.Request{
+			ReplaceAllText: &docs.ReplaceAllTextRequest{
+				ContainsText: &docs.SubstringMatchCriteria{Text: token, MatchCase: true},
+				ReplaceText:  values[token],
+				// An empty value still has to be sent to remove the token.
+				ForceSendFields: []string{"ReplaceText"},
+			},
+		})
+	}
+
+	replaced := map[string]int64{}
+	if len(requests) == 0 {
+		return replaced, nil
+	}
+
+	resp, err := batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{Requests: requests})
+	if err != nil {
+		return nil, err
+	}
+	for i, reply := range resp.Replies {
+		if i < len(tokens) && reply.ReplaceAllText != nil {
+			replaced[tokens[i]] = reply.ReplaceAllText.OccurrencesChanged
+		}
+	}
+	return replaced, nil
+}
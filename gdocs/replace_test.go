@@ -0,0 +1,57 @@
+package gdocs
+
+import (
+	"context"
+	"hflabstesttask/internal/docstest"
+	"testing"
+)
+
+func TestReplaceAllText(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	docId := fake.AddDocument("Template",
+		docstest.Element{Text: "Admin: {{admin}}, {{ADMIN}}\n"},
+		docstest.Element{Cells: [][]string{{"{{admin}}\n", "{{removed}}\n"}}},
+		docstest.Element{Text: "\n"},
+	)
+
+	replaced, err := ReplaceAllText(context.Background(), docId, srv, map[string]string{
+		"{{admin}}":   "Ann",
+		"{{removed}}": "",
+		"{{missing}}": "Bob",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{"{{admin}}": 2, "{{removed}}": 1, "{{missing}}": 0}
+	for token, count := range want {
+		if replaced[token] != count {
+			t.Errorf("replaced %v of %q, want %v", replaced[token], token, count)
+		}
+	}
+	if batches := fake.Batches(); len(batches) != 1 || len(batches[0].Requests) != 3 {
+		t.Errorf("sent %v batch updates, want a single one with a request per token", len(batches))
+	}
+
+	doc := fake.Document(docId)
+	if doc.Elements[0].Text != "Admin: Ann, {{ADMIN}}\n" {
+		t.Errorf("paragraph = %q, the tokens are matched case-sensitively", doc.Elements[0].Text)
+	}
+	if cells := doc.Elements[1].Cells[0]; cells[0] != "Ann\n" || cells[1] != "\n" {
+		t.Errorf("cells = %q, want the tokens replaced and the empty one removed", cells)
+	}
+}
+
+func TestReplaceAllTextWithoutValues(t *testing.T) {
+	fake := docstest.NewServer(t)
+	docId := fake.AddDocument("Template")
+
+	replaced, err := ReplaceAllText(context.Background(), docId, fake.Service(t), map[string]string{})
+	if err != nil || len(replaced) != 0 {
+		t.Fatalf("ReplaceAllText() = %v, %v, want nothing replaced", replaced, err)
+	}
+	if len(fake.Batches()) != 0 {
+		t.Errorf("sent a batch update without requests")
+	}
+}
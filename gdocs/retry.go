@@ -0,0 +1,54 @@
+package gdocs
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/googleapi"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// The Docs API intermittently fails with "Internal error encountered", such errors are worth retrying.
+func isTransientError(err error) bool {
+	apiErr := &googleapi.Error{}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.Code == http.StatusInternalServerError || apiErr.Code == http.StatusServiceUnavailable {
+		return true
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == "internalError" || item.Reason == "backendError" {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies request, retrying transient errors with exponential backoff and jitter. A BatchUpdate is applied
+// atomically, but a request that failed with a 500 may still have been applied, so a retry could apply it twice.
+// Set request.WriteControl.RequiredRevisionId when the revision is known: a retry of an applied request
+// then fails instead of, say, inserting the same text twice.
+func batchUpdate(ctx context.Context, srv *docs.Service, docId string, request *docs.BatchUpdateDocumentRequest) (*docs.BatchUpdateDocumentResponse, error) {
+	backoff := DOCS_INITIAL_BACKOFF
+	for attempt := 1; ; attempt++ {
+		resp, err := srv.Documents.BatchUpdate(docId, request).Context(ctx).Do()
+		slog.Debug("BatchUpdate response", "response", resp)
+		if err == nil || attempt >= DOCS_RETRY_ATTEMPTS || !isTransientError(err) {
+			return resp, err
+		}
+
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		slog.Warn("BatchUpdate failed, retrying", "attempt", attempt, "attempts", DOCS_RETRY_ATTEMPTS, "err", err, "retry_in", sleep)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,34 @@
+package gdocs
+
+import (
+	"hflabstesttask/scrape"
+	"sync/atomic"
+)
+
+// Counters of the whole process, shared by every service and document.
+var apiCalls, insertedRows, insertedCells atomic.Int64
+
+type Stats struct {
+	// Requests sent to the Google APIs, rate limit retries included.
+	APICalls int64
+	// Rows and cells of the inserted tables.
+	Rows  int64
+	Cells int64
+}
+
+func CurrentStats() Stats {
+	return Stats{APICalls: apiCalls.Load(), Rows: insertedRows.Load(), Cells: insertedCells.Load()}
+}
+
+func countInserted(tbl scrape.Table) {
+	insertedRows.Add(int64(len(tbl.Contents)))
+	for _, row := range tbl.Contents {
+		insertedCells.Add(int64(len(row.Cells)))
+	}
+}
+
+func countInsertedTables(tables []scrape.Table, indices []int) {
+	for _, i := range indices {
+		countInserted(tables[i])
+	}
+}
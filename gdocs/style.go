@@ -0,0 +1,120 @@
+package gdocs
+
+import (
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/scrape"
+	"strconv"
+	"strings"
+)
+
+// Parses a color such as #f3f3f3 or f3f3f3.
+func ParseHexColor(s string) (*docs.RgbColor, error) {
+	hex := strings.TrimPrefix(s, "#")
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("Invalid color %q, expected #rrggbb", s)
+	}
+
+	return &docs.RgbColor{
+		Red:   float64(value>>16&0xff) / 255,
+		Green: float64(value>>8&0xff) / 255,
+		Blue:  float64(value&0xff) / 255,
+	}, nil
+}
+
+// Colors the background of every other row after the header rows, starting with the second one.
+func stripeRequests(tableStart *docs.Location, tbl scrape.Table, color *docs.RgbColor) []*docs.Request {
+	requests := []*docs.Request{}
+	dataRow := 0
+	for rowIdx, row := range tbl.Contents {
+		if row.Header {
+			continue
+		}
+
+		if dataRow%2 == 1 {
+			requests = append(requests, &docs.Request{
+				UpdateTableCellStyle: &docs.UpdateTableCellStyleRequest{
+					TableRange: &docs.TableRange{
+						TableCellLocation: &docs.TableCellLocation{
+							TableStartLocation: tableStart,
+							RowIndex:           int64(rowIdx),
+						},
+						RowSpan:    1,
+						ColumnSpan: int64(len(row.Cells)),
+					},
+					TableCellStyle: &docs.TableCellStyle{
+						BackgroundColor: &docs.OptionalColor{Color: &docs.Color{RgbColor: color}},
+					},
+					Fields: "backgroundColor",
+				},
+			})
+		}
+		dataRow++
+	}
+	return requests
+}
+
+// Dash styles of the borders, named like the Docs API ones.
+const BORDER_SOLID = "SOLID"
+const BORDER_DOT = "DOT"
+const BORDER_DASH = "DASH"
+
+// Borders of every cell of a table.
+type Borders struct {
+	// Hide the borders, the other fields are ignored then.
+	None bool
+	// Black if nil.
+	Color *docs.RgbColor
+	// Width in points, 1 if 0.
+	Width float64
+	// One of the BORDER_* constants, BORDER_SOLID if empty.
+	Style string
+}
+
+// Sets the four borders of every cell of the table, rowCnt by colCnt cells from its structure in the document.
+// The Docs API needs the color, width and dash style of every border it sets, a hidden border has width 0.
+func borderRequests(tableStart *docs.Location, rowCnt int64, colCnt int64, borders Borders) []*docs.Request {
+	color := borders.Color
+	if color == nil {
+		color = &docs.RgbColor{}
+	}
+	width := borders.Width
+	if width == 0 {
+		width = 1
+	}
+	style := borders.Style
+	if style == "" {
+		style = BORDER_SOLID
+	}
+	if borders.None {
+		width = 0
+		style = BORDER_SOLID
+	}
+
+	border := &docs.TableCellBorder{
+		Color:     &docs.OptionalColor{Color: &docs.Color{RgbColor: color}},
+		Width:     &docs.Dimension{Magnitude: width, Unit: "PT"},
+		DashStyle: style,
+		// A zero width is left out of the request otherwise.
+		ForceSendFields: []string{"Width"},
+	}
+	border.Width.ForceSendFields = []string{"Magnitude"}
+
+	return []*docs.Request{{
+		UpdateTableCellStyle: &docs.UpdateTableCellStyleRequest{
+			TableRange: &docs.TableRange{
+				TableCellLocation: &docs.TableCellLocation{TableStartLocation: tableStart},
+				RowSpan:           rowCnt,
+				ColumnSpan:        colCnt,
+			},
+			TableCellStyle: &docs.TableCellStyle{
+				BorderTop:    border,
+				BorderBottom: border,
+				BorderLeft:   border,
+				BorderRight:  border,
+			},
+			Fields: "borderTop,borderBottom,borderLeft,borderRight",
+		},
+	}}
+}
@@ -0,0 +1,71 @@
+package gdocs
+
+import (
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/scrape"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *docs.RgbColor
+	}{
+		{"#ffffff", &docs.RgbColor{Red: 1, Green: 1, Blue: 1}},
+		{"000000", &docs.RgbColor{}},
+		{"#FF0000", &docs.RgbColor{Red: 1}},
+		{"#00ff00", &docs.RgbColor{Green: 1}},
+		{"#0000ff", &docs.RgbColor{Blue: 1}},
+		{"#fff", nil},
+		{"#1234567", nil},
+		{"zzzzzz", nil},
+		{"", nil},
+		{"#", nil},
+	}
+
+	for _, test := range tests {
+		got, err := ParseHexColor(test.in)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("ParseHexColor(%q) = %+v, want an error", test.in, got)
+			}
+			continue
+		}
+		if err != nil || got.Red != test.want.Red || got.Green != test.want.Green || got.Blue != test.want.Blue {
+			t.Errorf("ParseHexColor(%q) = %+v, %v, want %+v", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestStripeRequests(t *testing.T) {
+	header := scrape.NewRow("h")
+	header.Header = true
+	tests := []struct {
+		name string
+		rows []scrape.Row
+		want []int64
+	}{
+		{"no header", []scrape.Row{scrape.NewRow("a"), scrape.NewRow("b"), scrape.NewRow("c"), scrape.NewRow("d")}, []int64{1, 3}},
+		{"after the header", []scrape.Row{header, scrape.NewRow("a"), scrape.NewRow("b"), scrape.NewRow("c")}, []int64{2}},
+		{"one row", []scrape.Row{scrape.NewRow("a")}, []int64{}},
+	}
+
+	color := &docs.RgbColor{Red: 0.5}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := stripeRequests(&docs.Location{Index: 2}, scrape.Table{Contents: test.rows}, color)
+			if len(requests) != len(test.want) {
+				t.Fatalf("stripeRequests() returned %v requests, want the rows %v", len(requests), test.want)
+			}
+			for i, request := range requests {
+				location := request.UpdateTableCellStyle.TableRange.TableCellLocation
+				if location.RowIndex != test.want[i] || location.TableStartLocation.Index != 2 {
+					t.Errorf("request %v colors row %v of the table at %v, want row %v of the table at 2", i, location.RowIndex, location.TableStartLocation.Index, test.want[i])
+				}
+				if request.UpdateTableCellStyle.TableCellStyle.BackgroundColor.Color.RgbColor != color {
+					t.Errorf("request %v doesn't use the stripe color", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package gdocs
+
+import (
+	"context"
+	"google.golang.org/api/docs/v1"
+	"strconv"
+	"strings"
+)
+
+const TOC_TITLE = "Contents"
+
+// Indentation of a table of contents entry per heading level below the top one.
+const TOC_INDENT = 18.0
+
+// A heading of the document, as an entry of the table of contents.
+type tocEntry struct {
+	text      string
+	headingId string
+	level     int
+}
+
+// Returns the headings of the body in document order. Docs gives every heading paragraph a heading id.
+func tocEntries(body *docs.Body) []tocEntry {
+	entries := []tocEntry{}
+	if body == nil {
+		return entries
+	}
+
+	for _, element := range body.Content {
+		paragraph := element.Paragraph
+		if paragraph == nil || paragraph.ParagraphStyle == nil || paragraph.ParagraphStyle.HeadingId == "" {
+			continue
+		}
+		level, err := strconv.Atoi(strings.TrimPrefix(paragraph.ParagraphStyle.NamedStyleType, "HEADING_"))
+		if err != nil {
+			continue
+		}
+
+		text := strings.Builder{}
+		for _, run := range paragraph.Elements {
+			if run.TextRun != nil {
+				text.WriteString(run.TextRun.Content)
+			}
+		}
+		if entry := strings.Join(strings.Fields(text.String()), " "); entry != "" {
+			entries = append(entries, tocEntry{text: entry, headingId: paragraph.ParagraphStyle.HeadingId, level: level})
+		}
+	}
+	return entries
+}
+
+// Inserts a list of links to the headings of the document at its beginning, indented by heading level.
+// The Docs API can't insert a real table of contents, only the editor can, so the list isn't updated
+// by Docs when the headings change. Returns the number of entries, nothing is inserted without headings.
+func InsertTableOfContents(ctx context.Context, docId string, srv *docs.Service) (int, error) {
+	doc, err := srv.Documents.Get(docId).Context(ctx).Do()
+	if err != nil {
+		return 0, err
+	}
+
+	entries := tocEntries(doc.Body)
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	topLevel := entries[0].level
+	for _, entry := range entries {
+		topLevel = min(topLevel, entry.level)
+	}
+
+	// Index 0 is the section break that starts the body.
+	const start = int64(1)
+	text := TOC_TITLE + "\n"
+	styleRequests := []*docs.Request{{
+		UpdateTextStyle: &docs.UpdateTextStyleRequest{
+			Range:     &docs.Range{StartIndex: start, EndIndex: start + utf16Length(TOC_TITLE)},
+			TextStyle: &docs.TextStyle{Bold: true},
+			Fields:    "bold",
+		},
+	}}
+	for _, entry := range entries {
+		entryStart := start + utf16Length(text)
+		entryEnd := entryStart + utf16Length(entry.text)
+		text += entry.text + "\n"
+
+		styleRequests = append(styleRequests, &docs.Request{
+			UpdateTextStyle: &docs.UpdateTextStyleRequest{
+				Range:     &docs.Range{StartIndex: entryStart, EndIndex: entryEnd},
+				TextStyle: &docs.TextStyle{Link: &docs.Link{HeadingId: entry.headingId}},
+				Fields:    "link",
+			},
+		})
+		if indent := float64(entry.level-topLevel) * TOC_INDENT; indent > 0 {
+			styleRequests = append(styleRequests, &docs.Request{
+				UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+					Range:          &docs.Range{StartIndex: entryStart, EndIndex: entryEnd},
+					ParagraphStyle: &docs.ParagraphStyle{IndentStart: &docs.Dimension{Magnitude: indent, Unit: "PT"}},
+					Fields:         "indentStart",
+				},
+			})
+		}
+	}
+
+	// The inserted paragraphs take the style of the paragraph they are inserted into, which may be a heading.
+	requests := []*docs.Request{
+		{InsertText: &docs.InsertTextRequest{Location: &docs.Location{Index: start}, Text: text}},
+		{UpdateParagraphStyle: &docs.UpdateParagraphStyleRequest{
+			Range:          &docs.Range{StartIndex: start, EndIndex: start + utf16Length(text)},
+			ParagraphStyle: &docs.ParagraphStyle{NamedStyleType: "NORMAL_TEXT"},
+			Fields:         "namedStyleType",
+		}},
+	}
+	_, err = batchUpdate(ctx, srv, docId, &docs.BatchUpdateDocumentRequest{
+		WriteControl: &docs.WriteControl{RequiredRevisionId: doc.RevisionId},
+		Requests:     append(requests, styleRequests...),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
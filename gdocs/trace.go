@@ -0,0 +1,102 @@
+package gdocs
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Longer bodies, such as a Get of a big document, are cut in the log.
+const TRACE_MAX_BODY = 64 << 10
+
+const TRACE_REDACTED = "REDACTED"
+
+// Headers and query parameters carrying credentials, they are never logged.
+var traceSecretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Goog-Api-Key"}
+var traceSecretParams = []string{"access_token", "key"}
+
+// Logs every Google API request and response with their bodies, for debugging what exactly is sent.
+// It sits below the rate limiter, so every retry is logged too, and above the transport adding the bearer
+// token, so the token never passes through it. The credential headers are redacted all the same.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func redactedHeaders(header http.Header) map[string]string {
+	redacted := map[string]string{}
+	for name := range header {
+		redacted[name] = header.Get(name)
+	}
+	for _, name := range traceSecretHeaders {
+		if header.Get(name) != "" {
+			redacted[http.CanonicalHeaderKey(name)] = TRACE_REDACTED
+		}
+	}
+	return redacted
+}
+
+func redactedURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for _, name := range traceSecretParams {
+		if query.Has(name) {
+			query.Set(name, TRACE_REDACTED)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func traceBody(b []byte) string {
+	if len(b) > TRACE_MAX_BODY {
+		return string(b[:TRACE_MAX_BODY]) + "... (truncated)"
+	}
+	return string(b)
+}
+
+func (t *tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// The request is cloned, a RoundTripper must not modify it.
+	traced := request.Clone(request.Context())
+	requestBody := []byte{}
+	if request.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		traced.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+	slog.Info("Google API request", "method", request.Method, "url", redactedURL(request.URL), "headers", redactedHeaders(request.Header), "body", traceBody(requestBody))
+
+	start := time.Now()
+	response, err := t.base.RoundTrip(traced)
+	if err != nil {
+		slog.Info("Google API request failed", "url", redactedURL(request.URL), "elapsed", time.Since(start), "err", err)
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+	slog.Info("Google API response", "url", redactedURL(request.URL), "status", response.Status, "elapsed", time.Since(start), "headers", redactedHeaders(response.Header), "body", traceBody(responseBody))
+	return response, nil
+}
+
+// Wraps the transport of client with a tracingTransport.
+func tracedClient(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	traced := *client
+	traced.Transport = &tracingTransport{base: base}
+	return &traced
+}
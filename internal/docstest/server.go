@@ -0,0 +1,384 @@
+// Package docstest fakes the Google Docs API for the tests of the packages writing documents. Like
+// httptest, it serves real HTTP, so the requests go through the docs client, the retries and the rate
+// limiter. A document is a list of paragraphs and tables, and the indices of Get follow the rules of
+// Google Docs closely enough for the insert and clear code: UTF-16 code units, a section break at 0,
+// and a table, each of its rows and each of its cells taking one index before their content.
+package docstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"google.golang.org/api/option"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+)
+
+// A paragraph, or a table of paragraphs when Cells is set. Text ends with the newline of the paragraph,
+// and the text of a cell with the newline of its paragraph too.
+type Element struct {
+	Text  string
+	Cells [][]string
+	// Named style, such as HEADING_2, NORMAL_TEXT if empty.
+	Style string
+}
+
+type Document struct {
+	Title    string
+	Elements []Element
+	Revision int
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	documents map[string]*Document
+	batches   []*docs.BatchUpdateDocumentRequest
+	created   int
+	// Called before every request, a true return means it has written the response, e.g. to fail the request.
+	Intercept func(w http.ResponseWriter, r *http.Request) bool
+}
+
+func NewServer(t testing.TB) *Server {
+	s := &Server{documents: map[string]*Document{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Returns a docs client of the fake.
+func (s *Server) Service(t testing.TB) *docs.Service {
+	srv, err := docs.NewService(context.Background(), option.WithEndpoint(s.URL+"/"), option.WithHTTPClient(s.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// Adds a document with the elements, an empty document when there are none, and returns its id.
+func (s *Server) AddDocument(title string, elements ...Element) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addDocument(title, elements)
+}
+
+func (s *Server) addDocument(title string, elements []Element) string {
+	s.created++
+	id := "doc-" + strconv.Itoa(s.created)
+	if len(elements) == 0 {
+		elements = []Element{{Text: "\n"}}
+	}
+	s.documents[id] = &Document{Title: title, Elements: elements, Revision: 1}
+	return id
+}
+
+// Returns a copy of the document, nil if there is none with the id.
+func (s *Server) Document(id string) *Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.documents[id]
+	if !ok {
+		return nil
+	}
+	copied := *doc
+	copied.Elements = append([]Element(nil), doc.Elements...)
+	return &copied
+}
+
+// Returns the batch updates received so far, in order.
+func (s *Server) Batches() []*docs.BatchUpdateDocumentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*docs.BatchUpdateDocumentRequest(nil), s.batches...)
+}
+
+// Returns the number of documents created with the API.
+func (s *Server) Created() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.created
+}
+
+func utf16Length(s string) int64 {
+	return int64(len(utf16.Encode([]rune(s))))
+}
+
+// Builds the API view of the document, with the indices.
+func (doc *Document) build(id string) *docs.Document {
+	content := []*docs.StructuralElement{{StartIndex: 0, EndIndex: 1, SectionBreak: &docs.SectionBreak{}}}
+	index := int64(1)
+	paragraph := func(text string, style string) *docs.StructuralElement {
+		if style == "" {
+			style = "NORMAL_TEXT"
+		}
+		element := &docs.StructuralElement{
+			StartIndex: index,
+			EndIndex:   index + utf16Length(text),
+			Paragraph: &docs.Paragraph{
+				Elements:       []*docs.ParagraphElement{{StartIndex: index, EndIndex: index + utf16Length(text), TextRun: &docs.TextRun{Content: text}}},
+				ParagraphStyle: &docs.ParagraphStyle{NamedStyleType: style},
+			},
+		}
+		if strings.HasPrefix(style, "HEADING_") {
+			element.Paragraph.ParagraphStyle.HeadingId = fmt.Sprintf("h.%v", index)
+		}
+		index = element.EndIndex
+		return element
+	}
+
+	for _, element := range doc.Elements {
+		if element.Cells == nil {
+			content = append(content, paragraph(element.Text, element.Style))
+			continue
+		}
+
+		table := &docs.StructuralElement{StartIndex: index, Table: &docs.Table{Rows: int64(len(element.Cells))}}
+		index++
+		for _, row := range element.Cells {
+			table.Table.Columns = int64(len(row))
+			tableRow := &docs.TableRow{StartIndex: index}
+			index++
+			for _, text := range row {
+				cell := &docs.TableCell{StartIndex: index}
+				index++
+				cell.Content = []*docs.StructuralElement{paragraph(text, "")}
+				cell.EndIndex = index
+				tableRow.TableCells = append(tableRow.TableCells, cell)
+			}
+			tableRow.EndIndex = index
+			table.Table.TableRows = append(table.Table.TableRows, tableRow)
+		}
+		table.EndIndex = index
+		content = append(content, table)
+	}
+
+	return &docs.Document{
+		DocumentId: id,
+		Title:      doc.Title,
+		RevisionId: strconv.Itoa(doc.Revision),
+		Body:       &docs.Body{Content: content},
+	}
+}
+
+// Inserts text before the newline ending the body, splitting it into paragraphs at its newlines.
+func (doc *Document) appendText(text string) {
+	last := &doc.Elements[len(doc.Elements)-1]
+	if last.Cells != nil {
+		doc.Elements = append(doc.Elements, Element{Text: "\n"})
+		last = &doc.Elements[len(doc.Elements)-1]
+	}
+
+	lines := strings.Split(strings.TrimSuffix(last.Text, "\n")+text, "\n")
+	doc.Elements = doc.Elements[:len(doc.Elements)-1]
+	for _, line := range lines {
+		doc.Elements = append(doc.Elements, Element{Text: line + "\n"})
+	}
+}
+
+// Inserts text at the index, which has to be in a paragraph of the body or of a cell.
+func (doc *Document) insertText(index int64, text string) error {
+	built := doc.build("")
+	insert := func(content string, start int64) (string, bool) {
+		offset := index - start
+		units := utf16.Encode([]rune(content))
+		if offset < 0 || offset >= int64(len(units)) {
+			return "", false
+		}
+		return string(utf16.Decode(units[:offset])) + text + string(utf16.Decode(units[offset:])), true
+	}
+
+	for i, element := range built.Body.Content[1:] {
+		if element.Paragraph != nil {
+			if updated, ok := insert(doc.Elements[i].Text, element.StartIndex); ok {
+				// The newlines of the text split the paragraph, the new paragraphs keep its style.
+				paragraphs := []Element{}
+				for _, line := range strings.SplitAfter(strings.TrimSuffix(updated, "\n"), "\n") {
+					paragraphs = append(paragraphs, Element{Text: line, Style: doc.Elements[i].Style})
+				}
+				paragraphs[len(paragraphs)-1].Text += "\n"
+				doc.Elements = append(doc.Elements[:i], append(paragraphs, doc.Elements[i+1:]...)...)
+				return nil
+			}
+			continue
+		}
+		for r, row := range element.Table.TableRows {
+			for c, cell := range row.TableCells {
+				if updated, ok := insert(doc.Elements[i].Cells[r][c], cell.Content[0].StartIndex); ok {
+					doc.Elements[i].Cells[r][c] = updated
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("Index %v isn't in a paragraph", index)
+}
+
+// Deletes the elements the range covers, keeping the newline ending the body.
+func (doc *Document) deleteRange(r *docs.Range) {
+	built := doc.build("")
+	kept := []Element{}
+	for i, element := range built.Body.Content[1:] {
+		if element.StartIndex < r.StartIndex || element.EndIndex > r.EndIndex+1 {
+			kept = append(kept, doc.Elements[i])
+		}
+	}
+	if len(kept) == 0 || kept[len(kept)-1].Cells != nil {
+		kept = append(kept, Element{Text: "\n"})
+	}
+	doc.Elements = kept
+}
+
+// Applies request to doc, returning its reply, which only ReplaceAllText fills.
+func (doc *Document) apply(request *docs.Request) (*docs.Response, error) {
+	reply := &docs.Response{}
+	switch {
+	case request.InsertText != nil && request.InsertText.EndOfSegmentLocation != nil:
+		doc.appendText(request.InsertText.Text)
+	case request.InsertText != nil:
+		return reply, doc.insertText(request.InsertText.Location.Index, request.InsertText.Text)
+	case request.InsertTable != nil:
+		cells := make([][]string, request.InsertTable.Rows)
+		for i := range cells {
+			cells[i] = make([]string, request.InsertTable.Columns)
+			for j := range cells[i] {
+				cells[i][j] = "\n"
+			}
+		}
+		doc.Elements = append(doc.Elements, Element{Cells: cells}, Element{Text: "\n"})
+	case request.DeleteContentRange != nil:
+		doc.deleteRange(request.DeleteContentRange.Range)
+	case request.UpdateParagraphStyle != nil && request.UpdateParagraphStyle.ParagraphStyle.NamedStyleType != "":
+		built := doc.build("")
+		for i, element := range built.Body.Content[1:] {
+			if element.Paragraph != nil && element.StartIndex < request.UpdateParagraphStyle.Range.EndIndex && element.EndIndex > request.UpdateParagraphStyle.Range.StartIndex {
+				doc.Elements[i].Style = request.UpdateParagraphStyle.ParagraphStyle.NamedStyleType
+			}
+		}
+	case request.ReplaceAllText != nil:
+		reply.ReplaceAllText = &docs.ReplaceAllTextResponse{OccurrencesChanged: doc.replaceAll(request.ReplaceAllText)}
+	}
+	// The other requests only style the text.
+	return reply, nil
+}
+
+// Replaces the text in the paragraphs and the cells, case-sensitively. Returns the number of occurrences.
+func (doc *Document) replaceAll(request *docs.ReplaceAllTextRequest) int64 {
+	token := request.ContainsText.Text
+	if token == "" {
+		return 0
+	}
+
+	changed := 0
+	for i := range doc.Elements {
+		element := &doc.Elements[i]
+		changed += strings.Count(element.Text, token)
+		element.Text = strings.ReplaceAll(element.Text, token, request.ReplaceText)
+		for _, row := range element.Cells {
+			for j := range row {
+				changed += strings.Count(row[j], token)
+				row[j] = strings.ReplaceAll(row[j], token, request.ReplaceText)
+			}
+		}
+	}
+	return int64(changed)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": code, "message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if s.Intercept != nil && s.Intercept(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/documents")
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		request := &docs.Document{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id := s.addDocument(request.Title, nil)
+		writeJSON(w, s.documents[id].build(id))
+
+	case r.Method == http.MethodGet:
+		id := strings.TrimPrefix(path, "/")
+		doc, ok := s.documents[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Requested entity was not found.")
+			return
+		}
+		writeJSON(w, doc.build(id))
+
+	case r.Method == http.MethodPost && strings.HasSuffix(path, ":batchUpdate"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), ":batchUpdate")
+		doc, ok := s.documents[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Requested entity was not found.")
+			return
+		}
+		request := &docs.BatchUpdateDocumentRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.batches = append(s.batches, request)
+
+		if request.WriteControl != nil && request.WriteControl.RequiredRevisionId != "" && request.WriteControl.RequiredRevisionId != strconv.Itoa(doc.Revision) {
+			writeError(w, http.StatusBadRequest, "The document was modified since the required revision.")
+			return
+		}
+		// A failed request changes nothing, like the API.
+		updated := *doc
+		updated.Elements = deepCopy(doc.Elements)
+		replies := make([]*docs.Response, len(request.Requests))
+		for i, req := range request.Requests {
+			reply, err := updated.apply(req)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			replies[i] = reply
+		}
+		updated.Revision++
+		s.documents[id] = &updated
+		writeJSON(w, &docs.BatchUpdateDocumentResponse{DocumentId: id, Replies: replies})
+
+	default:
+		writeError(w, http.StatusNotFound, "Unknown method "+r.Method+" "+r.URL.Path)
+	}
+}
+
+func deepCopy(elements []Element) []Element {
+	copied := make([]Element, len(elements))
+	for i, element := range elements {
+		copied[i] = element
+		if element.Cells != nil {
+			copied[i].Cells = make([][]string, len(element.Cells))
+			for r, row := range element.Cells {
+				copied[i].Cells[r] = append([]string(nil), row...)
+			}
+		}
+	}
+	return copied
+}
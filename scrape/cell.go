@@ -0,0 +1,391 @@
+package scrape
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A styled run of a cell's text.
+type Span struct {
+	// Rune offsets into Cell.Text, End is exclusive.
+	Start int
+	End   int
+	// Target of a hyperlink, empty if the span isn't a link.
+	Link   string
+	Bold   bool
+	Italic bool
+	// The span is a list with one item per line, nested items start with a tab per level.
+	List bool
+	// The list is numbered rather than bulleted.
+	Ordered bool
+	// URL of an image the span stands for, its text is the alt text of the image.
+	Image string
+}
+
+type Cell struct {
+	Text  string
+	Spans []Span
+	// Horizontal alignment of the text, one of the ALIGN_* constants, empty keeps the default one.
+	Align string
+}
+
+// Alignments of the cell text, named like the Docs API paragraph alignments.
+const ALIGN_START = "START"
+const ALIGN_CENTER = "CENTER"
+const ALIGN_END = "END"
+const ALIGN_JUSTIFIED = "JUSTIFIED"
+
+var textAlignPattern = regexp.MustCompile(`(?i)text-align\s*:\s*([a-z-]+)`)
+
+// Maps a CSS text-align or an align attribute value to an ALIGN_* constant, "" if it isn't one.
+func alignment(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "left", "start":
+		return ALIGN_START
+	case "center", "-webkit-center":
+		return ALIGN_CENTER
+	case "right", "end":
+		return ALIGN_END
+	case "justify":
+		return ALIGN_JUSTIFIED
+	}
+	return ""
+}
+
+// Returns the alignment of the cell from its text-align style or align attribute, or else from those of
+// the first paragraph in it, as the Confluence editor aligns the paragraphs rather than the cell.
+func cellAlignment(cellSelection *goquery.Selection) string {
+	elementAlignment := func(selection *goquery.Selection) string {
+		if style, ok := selection.Attr("style"); ok {
+			if match := textAlignPattern.FindStringSubmatch(style); match != nil {
+				return alignment(match[1])
+			}
+		}
+		align, _ := selection.Attr("align")
+		return alignment(align)
+	}
+
+	if align := elementAlignment(cellSelection); align != "" {
+		return align
+	}
+	if paragraph := cellSelection.Find("p, div").First(); paragraph.Length() > 0 {
+		return elementAlignment(paragraph)
+	}
+	return ""
+}
+
+// Private use characters that never occur in Confluence content. They survive html2text
+// untouched, so they mark where links and styled runs start and end in the converted text.
+const LINK_START = '\ue000'
+const LINK_END = '\ue001'
+const BOLD_START = '\ue002'
+const BOLD_END = '\ue003'
+const ITALIC_START = '\ue004'
+const ITALIC_END = '\ue005'
+const LIST_START = '\ue006'
+const ORDERED_LIST_START = '\ue007'
+const LIST_END = '\ue008'
+const LIST_ITEM_START = '\ue009'
+const LIST_ITEM_END = '\ue00a'
+const IMAGE_START = '\ue00b'
+const IMAGE_END = '\ue00c'
+const LINE_BREAK = '\ue00d'
+
+// Elements whose boundaries are line breaks with ParseOptions.BrAsNewline. Lists are left to ParseOptions.Lists.
+const BLOCK_ELEMENTS = "p, div, h1, h2, h3, h4, h5, h6, blockquote, pre, table, tr"
+
+// A run of line break markers with the whitespace html2text put around them.
+var lineBreakPattern = regexp.MustCompile(`\s*(\x{e00d}\s*)+`)
+
+// Replaces every run of line break markers with a single newline, dropping the ones at the ends of the text.
+func breakLines(text string) string {
+	return strings.Trim(lineBreakPattern.ReplaceAllString(text, "\n"), "\n")
+}
+
+// Replaces the elements matched by selector with their contents wrapped in the start and end markers.
+// Nested elements are replaced first, since replacing an element detaches the elements inside it.
+func markElements(cellSelection *goquery.Selection, selector string, start rune, end rune) error {
+	elements := cellSelection.Find(selector)
+	for i := elements.Length() - 1; i >= 0; i-- {
+		element := elements.Eq(i)
+		html, err := element.Html()
+		if err != nil {
+			return err
+		}
+		element.ReplaceWithHtml(string(start) + html + string(end))
+	}
+	return nil
+}
+
+// Converts the cell to text, keeping the link targets and, if asked to, bold and italic runs as spans.
+// html2text always appends the href to the link text, so the href is dropped from a copy of the cell
+// and the link text is wrapped in markers instead. If the HTML of the cell can't be rendered,
+// the error is returned along with the plain text of the cell. If html2text fails, the error wraps
+// errTextFallback and the cell is converted from the text of its HTML, keeping the spans.
+func extractCell(cellSelection *goquery.Selection, opts ParseOptions) (Cell, error) {
+	plainCell := func(err error) (Cell, error) {
+		text := decodeEntities(cellSelection.Text())
+		if opts.NormalizeCells {
+			text = normalizeCell(text, opts.KeepLineBreaks)
+		}
+		return Cell{Text: text}, err
+	}
+	cellSelection = cellSelection.Clone()
+
+	links := []string{}
+	cellSelection.Find("a[href]").Each(func(i int, linkSelection *goquery.Selection) {
+		href, _ := linkSelection.Attr("href")
+		links = append(links, href)
+		linkSelection.RemoveAttr("href")
+		linkSelection.PrependHtml(string(LINK_START))
+		linkSelection.AppendHtml(string(LINK_END))
+	})
+
+	// html2text drops images, the alt text in markers takes their place.
+	images := []string{}
+	if opts.Images {
+		cellSelection.Find("img").Each(func(i int, imageSelection *goquery.Selection) {
+			src, _ := imageSelection.Attr("src")
+			alt, _ := imageSelection.Attr("alt")
+			images = append(images, src)
+			imageSelection.ReplaceWithHtml(string(IMAGE_START) + html.EscapeString(alt) + string(IMAGE_END))
+		})
+	}
+
+	if opts.PreserveFormatting {
+		if err := markElements(cellSelection, "strong, b", BOLD_START, BOLD_END); err != nil {
+			return plainCell(err)
+		}
+		if err := markElements(cellSelection, "em, i", ITALIC_START, ITALIC_END); err != nil {
+			return plainCell(err)
+		}
+	}
+
+	// Without the list elements html2text runs the items together, the markers put them on their own lines.
+	if opts.Lists == LISTS_LINES || opts.Lists == LISTS_BULLETS {
+		if err := markElements(cellSelection, "li", LIST_ITEM_START, LIST_ITEM_END); err != nil {
+			return plainCell(err)
+		}
+		if err := markElements(cellSelection, "ul", LIST_START, LIST_END); err != nil {
+			return plainCell(err)
+		}
+		if err := markElements(cellSelection, "ol", ORDERED_LIST_START, LIST_END); err != nil {
+			return plainCell(err)
+		}
+	}
+
+	if opts.BrAsNewline {
+		cellSelection.Find("br").ReplaceWithHtml(string(LINE_BREAK))
+		blocks := cellSelection.Find(BLOCK_ELEMENTS)
+		blocks.PrependHtml(string(LINE_BREAK))
+		blocks.AppendHtml(string(LINE_BREAK))
+	}
+
+	html, err := cellSelection.Html()
+	if err != nil {
+		return plainCell(err)
+	}
+	text, textErr := htmlToText(html)
+	text = decodeEntities(text)
+	// The markers aren't whitespace, so normalizing before removing them keeps the spans right.
+	if opts.NormalizeCells {
+		text = normalizeCell(text, opts.KeepLineBreaks)
+	}
+	if opts.BrAsNewline {
+		text = breakLines(text)
+	}
+	return cellFromMarkedText(text, links, images, opts.Lists == LISTS_BULLETS), textErr
+}
+
+// Non-breaking spaces keep words from wrapping and break the column layout in Google Docs.
+var nonBreakingSpaceReplacer = strings.NewReplacer("\u00a0", " ", "\u202f", " ", "\u2007", " ")
+
+// html2text decodes the entities of the markup, but double-escaped ones such as &amp;nbsp; in
+// Confluence storage format still come out as &nbsp;. Those are decoded too and non-breaking spaces
+// of any origin become regular spaces.
+func decodeEntities(s string) string {
+	if strings.ContainsRune(s, '&') {
+		s = html.UnescapeString(s)
+	}
+	return nonBreakingSpaceReplacer.Replace(s)
+}
+
+// Trims s and collapses every run of whitespace into a single space. With keepLineBreaks, runs containing
+// a line break collapse into a single newline instead, so the lines of <br> separated text survive.
+func normalizeCell(s string, keepLineBreaks bool) string {
+	b := strings.Builder{}
+	pendingSpace, pendingBreak := false, false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			pendingSpace = true
+			pendingBreak = pendingBreak || r == '\n'
+			continue
+		}
+
+		if b.Len() > 0 {
+			if keepLineBreaks && pendingBreak {
+				b.WriteRune('\n')
+			} else if pendingSpace {
+				b.WriteRune(' ')
+			}
+		}
+
+		pendingSpace, pendingBreak = false, false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// A run of the text between a pair of markers, nested pairs of the same kind merge into the outer run.
+type markedRun struct {
+	depth int
+	start int
+}
+
+// Removes the markers from text, turning the i-th link run into a span linking to links[i], the i-th image
+// run into a span of images[i] and the bold and italic runs into spans with the corresponding style. Every list item goes on
+// its own line, and the text after a list too. With bullets, each top-level list becomes a List span
+// and the nested items are indented with a tab per level.
+func cellFromMarkedText(text string, links []string, images []string, bullets bool) Cell {
+	cell := Cell{}
+	runes := []rune{}
+	link, bold, italic := markedRun{}, markedRun{}, markedRun{}
+	linkIdx, imageIdx := 0, 0
+	imageStart := 0
+
+	// Ordered flags of the lists the text is in, outermost first.
+	lists := []bool{}
+	listStart := 0
+	afterList := false
+	// The space html2text leaves between the items becomes the line break, so the offsets stay the same.
+	breakLine := func() {
+		if len(runes) == 0 || runes[len(runes)-1] == '\n' {
+			return
+		}
+		if runes[len(runes)-1] == ' ' {
+			runes[len(runes)-1] = '\n'
+		} else {
+			runes = append(runes, '\n')
+		}
+	}
+
+	open := func(run *markedRun) {
+		if run.depth == 0 {
+			run.start = len(runes)
+		}
+		run.depth++
+	}
+	// Reports whether the outermost run has just been closed and isn't empty.
+	closed := func(run *markedRun) bool {
+		if run.depth == 0 {
+			return false
+		}
+		run.depth--
+		return run.depth == 0 && len(runes) > run.start
+	}
+
+	for _, r := range text {
+		switch r {
+		case LINK_START:
+			open(&link)
+		case LINK_END:
+			if closed(&link) && linkIdx < len(links) {
+				cell.Spans = append(cell.Spans, Span{Start: link.start, End: len(runes), Link: links[linkIdx]})
+			}
+			linkIdx++
+		case BOLD_START:
+			open(&bold)
+		case BOLD_END:
+			if closed(&bold) {
+				cell.Spans = append(cell.Spans, Span{Start: bold.start, End: len(runes), Bold: true})
+			}
+		case ITALIC_START:
+			open(&italic)
+		case ITALIC_END:
+			if closed(&italic) {
+				cell.Spans = append(cell.Spans, Span{Start: italic.start, End: len(runes), Italic: true})
+			}
+		case IMAGE_START:
+			imageStart = len(runes)
+		case IMAGE_END:
+			// Even an image without alt text gets an empty span, so that it isn't lost.
+			if imageIdx < len(images) && images[imageIdx] != "" {
+				cell.Spans = append(cell.Spans, Span{Start: imageStart, End: len(runes), Image: images[imageIdx]})
+			}
+			imageIdx++
+		case LIST_START, ORDERED_LIST_START:
+			breakLine()
+			if len(lists) == 0 {
+				listStart = len(runes)
+			}
+			lists = append(lists, r == ORDERED_LIST_START)
+		case LIST_ITEM_START:
+			breakLine()
+			if bullets {
+				for i := 1; i < len(lists); i++ {
+					runes = append(runes, '\t')
+				}
+			}
+		case LIST_ITEM_END:
+		case LIST_END:
+			if len(lists) == 0 {
+				continue
+			}
+			ordered := lists[0]
+			lists = lists[:len(lists)-1]
+			if len(lists) == 0 {
+				for len(runes) > 0 && runes[len(runes)-1] == ' ' {
+					runes = runes[:len(runes)-1]
+				}
+				if bullets && len(runes) > listStart {
+					cell.Spans = append(cell.Spans, Span{Start: listStart, End: len(runes), List: true, Ordered: ordered})
+				}
+			}
+			afterList = true
+		default:
+			if afterList && r != ' ' {
+				breakLine()
+				afterList = false
+			}
+			// The markers split the whitespace runs, so normalizing leaves spaces on both sides of them.
+			if r == ' ' && (len(lists) > 0 || afterList) && (len(runes) == 0 || runes[len(runes)-1] == '\n' || runes[len(runes)-1] == ' ') {
+				continue
+			}
+			runes = append(runes, r)
+		}
+	}
+
+	// Trimming the space after a list may have cut the end of a span.
+	for i := range cell.Spans {
+		cell.Spans[i].End = min(cell.Spans[i].End, len(runes))
+		cell.Spans[i].Start = min(cell.Spans[i].Start, cell.Spans[i].End)
+	}
+	cell.Text = string(runes)
+	return cell
+}
+
+// Confluence links and images are mostly relative to the page, while Google Docs only accepts absolute URLs.
+func resolveLinks(tables []Table, base *url.URL) {
+	for _, tbl := range tables {
+		for _, row := range tbl.Contents {
+			for _, cell := range row.Cells {
+				for i, span := range cell.Spans {
+					if span.Link != "" {
+						if link, err := base.Parse(span.Link); err == nil {
+							cell.Spans[i].Link = link.String()
+						}
+					}
+					if span.Image != "" {
+						if image, err := base.Parse(span.Image); err == nil {
+							cell.Spans[i].Image = image.String()
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package scrape
+
+import "testing"
+
+func TestNormalizeCell(t *testing.T) {
+	tests := []struct {
+		in             string
+		keepLineBreaks bool
+		want           string
+	}{
+		{"", false, ""},
+		{"   ", false, ""},
+		{"  a  ", false, "a"},
+		{"a \t\n b", false, "a b"},
+		{"a\n\nb", false, "a b"},
+		{"a \n\n b  c", true, "a\nb c"},
+		{"\n a \n", true, "a"},
+		{"Жук  жук", false, "Жук жук"},
+	}
+
+	for _, test := range tests {
+		if got := normalizeCell(test.in, test.keepLineBreaks); got != test.want {
+			t.Errorf("normalizeCell(%q, %v) = %q, want %q", test.in, test.keepLineBreaks, got, test.want)
+		}
+	}
+}
+
+func TestParseNormalizedCells(t *testing.T) {
+	html := `<table class="confluenceTable"><tr><td>&nbsp;a&nbsp;&nbsp;b&nbsp;</td><td><p>x</p><p>y</p></td></tr></table>`
+	tests := []struct {
+		name string
+		opts ParseOptions
+		want []string
+	}{
+		// html2text trims the cell, the non-breaking spaces become spaces after it.
+		{"raw", ParseOptions{}, []string{"a  b", "x\n\ny"}},
+		{"normalized", ParseOptions{NormalizeCells: true}, []string{"a b", "x y"}},
+		{"line breaks kept", ParseOptions{NormalizeCells: true, KeepLineBreaks: true}, []string{"a b", "x\ny"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkTexts(t, parseTable1(t, html, test.opts), [][]string{test.want})
+		})
+	}
+}
+
+func TestDecodeEntities(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"a &amp; b", "a & b"},
+		{"&nbsp;left&nbsp;", " left "},
+		{"&lt;b&gt;", "<b>"},
+		{"&#1046;&#x436;", "Жж"},
+		{"a\u00a0b\u202fc\u2007d", "a b c d"},
+		{"&unknown; & alone", "&unknown; & alone"},
+	}
+
+	for _, test := range tests {
+		if got := decodeEntities(test.in); got != test.want {
+			t.Errorf("decodeEntities(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseDoubleEscapedEntities(t *testing.T) {
+	// Confluence storage format escapes the entities of the content once more.
+	tbl := parseTable1(t, `<table class="confluenceTable"><tr><td>a&amp;nbsp;b &amp;mdash; c</td><td>Tom &amp;amp; Jerry</td></tr></table>`, ParseOptions{NormalizeCells: true})
+	checkTexts(t, tbl, [][]string{{"a b — c", "Tom & Jerry"}})
+}
+
+func TestParseLists(t *testing.T) {
+	html := `<table class="confluenceTable"><tr><td>Intro<ul><li>one</li><li>two<ol><li>sub</li></ol></li></ul>after</td><td><ol><li>first</li><li>second</li></ol></td></tr></table>`
+	tests := []struct {
+		lists string
+		want  []string
+		spans [][]Span
+	}{
+		// html2text stars the items of the numbered lists too.
+		{LISTS_TEXT, []string{"Intro * one * two * sub after", "* first * second"}, [][]Span{{}, {}}},
+		{LISTS_LINES, []string{"Intro\none\ntwo\nsub\nafter", "first\nsecond"}, [][]Span{{}, {}}},
+		{
+			LISTS_BULLETS,
+			[]string{"Intro\none\ntwo\n\tsub\nafter", "first\nsecond"},
+			[][]Span{{{Start: 6, End: 18, List: true}}, {{Start: 0, End: 12, List: true, Ordered: true}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.lists, func(t *testing.T) {
+			tbl := parseTable1(t, html, ParseOptions{NormalizeCells: true, Lists: test.lists})
+			checkTexts(t, tbl, [][]string{test.want})
+			for i, cell := range tbl.Contents[0].Cells {
+				if len(cell.Spans) != len(test.spans[i]) {
+					t.Errorf("cell %v spans = %+v, want %+v", i, cell.Spans, test.spans[i])
+					continue
+				}
+				for j := range cell.Spans {
+					if cell.Spans[j] != test.spans[i][j] {
+						t.Errorf("cell %v span %v = %+v, want %+v", i, j, cell.Spans[j], test.spans[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAlignment(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"left", ALIGN_START},
+		{"start", ALIGN_START},
+		{" Center ", ALIGN_CENTER},
+		{"-webkit-center", ALIGN_CENTER},
+		{"RIGHT", ALIGN_END},
+		{"end", ALIGN_END},
+		{"justify", ALIGN_JUSTIFIED},
+		{"inherit", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := alignment(test.in); got != test.want {
+			t.Errorf("alignment(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseAlignment(t *testing.T) {
+	html := `<table class="confluenceTable"><tr>` +
+		`<td style="text-align: center">style</td>` +
+		`<td align="right">attribute</td>` +
+		`<td><p style="text-align:right">paragraph</p><p style="text-align:left">second</p></td>` +
+		`<td style="color: red; TEXT-ALIGN: justify" align="left">style beats attribute</td>` +
+		`<td style="text-align: inherit">unsupported</td>` +
+		`<td>none</td>` +
+		`</tr></table>`
+	tests := []struct {
+		name string
+		opts ParseOptions
+		want []string
+	}{
+		{"preserved", ParseOptions{PreserveAlignment: true}, []string{ALIGN_CENTER, ALIGN_END, ALIGN_END, ALIGN_JUSTIFIED, "", ""}},
+		{"off", ParseOptions{}, []string{"", "", "", "", "", ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cells := parseTable1(t, html, test.opts).Contents[0].Cells
+			if len(cells) != len(test.want) {
+				t.Fatalf("parsed %v cells, want %v", len(cells), len(test.want))
+			}
+			for i, cell := range cells {
+				if cell.Align != test.want[i] {
+					t.Errorf("cell %q aligned %q, want %q", cell.Text, cell.Align, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	br := string(LINE_BREAK)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"no breaks", "no breaks"},
+		{"one" + br + "two", "one\ntwo"},
+		{"one " + br + " \n " + br + "two", "one\ntwo"},
+		{br + "a" + br + br + "b" + br, "a\nb"},
+		{br + " " + br, ""},
+		{"a\nb", "a\nb"},
+	}
+
+	for _, test := range tests {
+		if got := breakLines(test.in); got != test.want {
+			t.Errorf("breakLines(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseBrAsNewline(t *testing.T) {
+	tests := []struct {
+		name string
+		cell string
+		opts ParseOptions
+		want string
+	}{
+		{"br", "one<br>two<br/><br>three", ParseOptions{BrAsNewline: true}, "one\ntwo\nthree"},
+		{"paragraphs", "<p>a</p><p>b</p>tail", ParseOptions{BrAsNewline: true}, "a\nb\ntail"},
+		{"nested blocks", "<div><p>a</p></div><div>b</div>", ParseOptions{BrAsNewline: true}, "a\nb"},
+		{"bold", "<b>bold</b><br>plain", ParseOptions{BrAsNewline: true, PreserveFormatting: true}, "bold\nplain"},
+		{"normalized", "  one  <br>  two  ", ParseOptions{BrAsNewline: true, NormalizeCells: true}, "one\ntwo"},
+		{"normalized paragraphs", "<p>a  b</p><p>c</p>", ParseOptions{BrAsNewline: true, NormalizeCells: true}, "a b\nc"},
+		{"off", "one<br>two", ParseOptions{NormalizeCells: true}, "one two"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tbl := parseTable1(t, `<table class="confluenceTable"><tr><td>`+test.cell+`</td></tr></table>`, test.opts)
+			checkTexts(t, tbl, [][]string{{test.want}})
+		})
+	}
+}
+
+// The line breaks are collapsed before the markers of the spans are removed, so the spans stay right.
+func TestParseBrAsNewlineSpans(t *testing.T) {
+	tbl := parseTable1(t, `<table class="confluenceTable"><tr><td>one<br><br><b>bold</b></td></tr></table>`, ParseOptions{BrAsNewline: true, PreserveFormatting: true})
+	cell := tbl.Contents[0].Cells[0]
+	if cell.Text != "one\nbold" || len(cell.Spans) != 1 || cell.Spans[0].Start != 4 || cell.Spans[0].End != 8 || !cell.Spans[0].Bold {
+		t.Errorf("cell = %+v, want bold 4-8 of \"one\\nbold\"", cell)
+	}
+}
@@ -0,0 +1,106 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// Links to the child pages in the Children Display macro, the page tree macro and the children section
+// at the bottom of the page.
+const CRAWL_SELECTOR = ".childpages-macro a[href], .plugin_pagetree_children_content a[href], #page-children a[href]"
+
+// Stops a crawl of a big page tree, every page is fetched twice, once for its links and once for its tables.
+const CRAWL_MAX_PAGES = 100
+
+type CrawlOptions struct {
+	// How many levels of links below the start page to follow, 0 is the start page alone.
+	Depth int
+	// CSS selector of the links to follow, CRAWL_SELECTOR if empty.
+	Selector string
+}
+
+// Returns the absolute URLs of the links of the page at pageURL matching selector, without fragments.
+func pageLinks(ctx context.Context, client *http.Client, opts Options, pageURL string, selector string) ([]string, error) {
+	opts.URL = pageURL
+	response, err := fetchWithRetry(ctx, client, func() (*http.Request, error) {
+		return newRequest(ctx, opts)
+	}, opts.Attempts, opts.MaxElapsed)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := utf8Reader(response.Body, response.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	document, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []string{}
+	document.Find(selector).Each(func(i int, linkSelection *goquery.Selection) {
+		href, _ := linkSelection.Attr("href")
+		link, err := response.Request.URL.Parse(href)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			return
+		}
+		link.Fragment = ""
+		links = append(links, link.String())
+	})
+	return links, nil
+}
+
+// Follows the links matching crawl.Selector from the page at opts.URL down to crawl.Depth levels, breadth
+// first, and returns the URLs of the start page and the pages found, in that order. Only links to the host
+// of the start page are followed, every page is visited once, and the crawl stops at CRAWL_MAX_PAGES pages.
+func CrawlPages(ctx context.Context, client *http.Client, opts Options, crawl CrawlOptions) ([]string, error) {
+	start, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid start URL %q: %v", opts.URL, err)
+	}
+	selector := crawl.Selector
+	if selector == "" {
+		selector = CRAWL_SELECTOR
+	}
+	if err := ValidateSelector(selector); err != nil {
+		return nil, err
+	}
+
+	pages := []string{opts.URL}
+	visited := map[string]bool{opts.URL: true}
+	level := []string{opts.URL}
+	for depth := 0; depth < crawl.Depth && len(level) > 0; depth++ {
+		next := []string{}
+		for _, pageURL := range level {
+			links, err := pageLinks(ctx, client, opts, pageURL, selector)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to crawl %v: %w", pageURL, err)
+			}
+
+			for _, link := range links {
+				linkURL, err := url.Parse(link)
+				if err != nil || linkURL.Host != start.Host || visited[link] {
+					continue
+				}
+				if len(pages) >= CRAWL_MAX_PAGES {
+					slog.Warn("Stopped crawling at the page limit", "pages", len(pages), "depth", depth+1)
+					return pages, nil
+				}
+
+				visited[link] = true
+				pages = append(pages, link)
+				next = append(next, link)
+				slog.Debug("Found page", "url", link, "depth", depth+1)
+			}
+		}
+		level = next
+	}
+
+	return pages, nil
+}
@@ -0,0 +1,148 @@
+package scrape
+
+// A row only in the old or only in the new tables.
+type RowChange struct {
+	// Index of the row in the old table for a removed row, in the new table for an added one.
+	Row   int      `json:"row"`
+	Texts []string `json:"texts"`
+}
+
+// A cell whose text differs between a removed row and the added row that took its place.
+type CellChange struct {
+	// Index of the row and the column in the new table.
+	Row int    `json:"row"`
+	Col int    `json:"col"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type TableDiff struct {
+	// Index of the table, tables are matched by index.
+	Index   int    `json:"index"`
+	Heading string `json:"heading,omitempty"`
+	// Set when the table is only in the new or only in the old tables, all its rows are then added or removed.
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+
+	AddedRows    []RowChange  `json:"added_rows,omitempty"`
+	RemovedRows  []RowChange  `json:"removed_rows,omitempty"`
+	ChangedCells []CellChange `json:"changed_cells,omitempty"`
+}
+
+func (d TableDiff) Empty() bool {
+	return !d.Added && !d.Removed && len(d.AddedRows) == 0 && len(d.RemovedRows) == 0 && len(d.ChangedCells) == 0
+}
+
+// The changes between two scrapes of a page, only the tables with changes are listed.
+type Diff struct {
+	Tables []TableDiff `json:"tables"`
+}
+
+func (d Diff) Empty() bool {
+	return len(d.Tables) == 0
+}
+
+// Compares the cell texts of the old and new tables, matching the tables by index. The rows are matched
+// with a longest common subsequence of the unchanged rows, so inserting a row doesn't mark every row
+// below it as changed. A run of removed rows followed by added ones is paired up row by row into
+// changed cells, the unpaired rest stays removed or added.
+func DiffTables(old []Table, new []Table) Diff {
+	diff := Diff{Tables: []TableDiff{}}
+	for i := 0; i < max(len(old), len(new)); i++ {
+		tableDiff := TableDiff{Index: i}
+		switch {
+		case i >= len(old):
+			tableDiff.Heading = new[i].Heading
+			tableDiff.Added = true
+			tableDiff.AddedRows = rowChanges(new[i].Contents, 0)
+		case i >= len(new):
+			tableDiff.Heading = old[i].Heading
+			tableDiff.Removed = true
+			tableDiff.RemovedRows = rowChanges(old[i].Contents, 0)
+		default:
+			tableDiff.Heading = new[i].Heading
+			diffRows(&tableDiff, old[i].Contents, new[i].Contents)
+		}
+
+		if !tableDiff.Empty() {
+			diff.Tables = append(diff.Tables, tableDiff)
+		}
+	}
+	return diff
+}
+
+// Lists rows as changes, numbering them from first.
+func rowChanges(rows []Row, first int) []RowChange {
+	changes := []RowChange{}
+	for i, row := range rows {
+		changes = append(changes, RowChange{Row: first + i, Texts: row.Texts()})
+	}
+	return changes
+}
+
+func diffRows(tableDiff *TableDiff, old []Row, new []Row) {
+	// common[i][j] is the length of the longest common subsequence of old[i:] and new[j:].
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if sameTexts(old[i].Texts(), new[j].Texts()) {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	removed, added := []int{}, []int{}
+	flush := func() {
+		paired := min(len(removed), len(added))
+		for k := 0; k < paired; k++ {
+			diffCells(tableDiff, old[removed[k]], new[added[k]], added[k])
+		}
+		for _, rowIdx := range removed[paired:] {
+			tableDiff.RemovedRows = append(tableDiff.RemovedRows, RowChange{Row: rowIdx, Texts: old[rowIdx].Texts()})
+		}
+		for _, rowIdx := range added[paired:] {
+			tableDiff.AddedRows = append(tableDiff.AddedRows, RowChange{Row: rowIdx, Texts: new[rowIdx].Texts()})
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && sameTexts(old[i].Texts(), new[j].Texts()):
+			flush()
+			i++
+			j++
+		case j >= len(new) || i < len(old) && common[i+1][j] >= common[i][j+1]:
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	flush()
+}
+
+// Compares the cells of two rows, a missing cell counts as an empty one.
+func diffCells(tableDiff *TableDiff, old Row, new Row, rowIdx int) {
+	oldTexts, newTexts := old.Texts(), new.Texts()
+	for col := 0; col < max(len(oldTexts), len(newTexts)); col++ {
+		oldText, newText := "", ""
+		if col < len(oldTexts) {
+			oldText = oldTexts[col]
+		}
+		if col < len(newTexts) {
+			newText = newTexts[col]
+		}
+
+		if oldText != newText {
+			tableDiff.ChangedCells = append(tableDiff.ChangedCells, CellChange{Row: rowIdx, Col: col, Old: oldText, New: newText})
+		}
+	}
+}
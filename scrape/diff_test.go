@@ -0,0 +1,70 @@
+package scrape
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffTables(t *testing.T) {
+	users := newTable([]string{"Name", "Age"}, []string{"Ann", "30"}, []string{"Bob", "25"})
+	tests := []struct {
+		name string
+		old  []Table
+		new  []Table
+		want string
+	}{
+		{"unchanged", []Table{users}, []Table{users}, `{"tables":[]}`},
+		{
+			"changed cell",
+			[]Table{users},
+			[]Table{newTable([]string{"Name", "Age"}, []string{"Ann", "31"}, []string{"Bob", "25"})},
+			`{"tables":[{"index":0,"changed_cells":[{"row":1,"col":1,"old":"30","new":"31"}]}]}`,
+		},
+		{
+			"inserted row",
+			[]Table{users},
+			[]Table{newTable([]string{"Name", "Age"}, []string{"Eve", "41"}, []string{"Ann", "30"}, []string{"Bob", "25"})},
+			`{"tables":[{"index":0,"added_rows":[{"row":1,"texts":["Eve","41"]}]}]}`,
+		},
+		{
+			"removed row",
+			[]Table{users},
+			[]Table{newTable([]string{"Name", "Age"}, []string{"Bob", "25"})},
+			`{"tables":[{"index":0,"removed_rows":[{"row":1,"texts":["Ann","30"]}]}]}`,
+		},
+		{
+			"replaced rows",
+			[]Table{users},
+			[]Table{newTable([]string{"Name", "Age"}, []string{"Ann", "30", "new"}, []string{"Eve", "41"}, []string{"Zed", "50"})},
+			`{"tables":[{"index":0,"added_rows":[{"row":3,"texts":["Zed","50"]}],"changed_cells":[{"row":1,"col":2,"old":"","new":"new"},{"row":2,"col":0,"old":"Bob","new":"Eve"},{"row":2,"col":1,"old":"25","new":"41"}]}]}`,
+		},
+		{
+			"added table",
+			[]Table{},
+			[]Table{{Heading: "New", Contents: []Row{NewRow("a")}}},
+			`{"tables":[{"index":0,"heading":"New","added":true,"added_rows":[{"row":0,"texts":["a"]}]}]}`,
+		},
+		{
+			"removed table",
+			[]Table{users, {Heading: "Old", Contents: []Row{NewRow("a")}}},
+			[]Table{users},
+			`{"tables":[{"index":1,"heading":"Old","removed":true,"removed_rows":[{"row":0,"texts":["a"]}]}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := DiffTables(test.old, test.new)
+			got, err := json.Marshal(diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("DiffTables() = %s, want %s", got, test.want)
+			}
+			if diff.Empty() != (test.want == `{"tables":[]}`) {
+				t.Errorf("Empty() = %v", diff.Empty())
+			}
+		})
+	}
+}
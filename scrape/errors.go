@@ -0,0 +1,15 @@
+package scrape
+
+import "errors"
+
+// Errors returned by this package wrap one of these, so that callers can tell the failures apart with errors.Is.
+var (
+	// Confluence refused the request or served its login form, the credentials are missing or wrong.
+	ErrAccessDenied = errors.New("Access denied")
+	// The page doesn't exist, the URL is wrong.
+	ErrPageNotFound = errors.New("Page not found")
+	// The page has no table matching the selector or the filter.
+	ErrNoTables = errors.New("No tables")
+	// Confluence doesn't tell when the page was last modified, see LastModified.
+	ErrNoLastModified = errors.New("Last modification time unavailable")
+)
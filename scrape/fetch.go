@@ -0,0 +1,195 @@
+package scrape
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const FETCH_INITIAL_BACKOFF = 500 * time.Millisecond
+
+type Options struct {
+	// A file:// URL is read from disk like HtmlFile.
+	URL string
+	// Saved HTML page to read instead of fetching URL.
+	HtmlFile string
+
+	// Maximum number of attempts and total time spent retrying the fetch.
+	Attempts   int
+	MaxElapsed time.Duration
+
+	// Either User and Pass for HTTP Basic Auth or a personal access Token.
+	User  string
+	Pass  string
+	Token string
+
+	// Sent with every page request, as some firewalls block requests without a known User-Agent.
+	UserAgent string
+	// Extra headers of the page requests, set after the others, so they can override any of them.
+	Headers map[string]string
+
+	// Write the raw page to this path while parsing it, and the status and headers to the path plus ".headers".
+	SaveHtml string
+
+	Parse ParseOptions
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500
+}
+
+type ClientOptions struct {
+	// Bounds the whole request, reading the response body included.
+	Timeout time.Duration
+	// Proxy URL for all requests. If empty, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used.
+	Proxy string
+	// PEM bundle of CA certificates trusted in addition to the system ones, for a Confluence with a private CA.
+	CACert string
+	// Don't verify the server certificate at all, which lets anyone in the middle read the traffic.
+	InsecureSkipVerify bool
+}
+
+func tlsConfig(opts ClientOptions) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification is DISABLED for Confluence requests, the connection can be intercepted")
+	}
+
+	if opts.CACert != "" {
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificates: %v", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No PEM certificates found in %v", opts.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// Builds the client used to talk to Confluence. Client.Timeout also bounds reading the response body,
+// so a server that stalls mid-body fails the request instead of hanging forever.
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	config, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = config
+
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy URL %q", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Timeout: opts.Timeout, Transport: transport}, nil
+}
+
+// Builds the GET request for the Confluence page with the configured credentials and headers.
+func newRequest(ctx context.Context, opts Options) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.UserAgent != "" {
+		request.Header.Set("User-Agent", opts.UserAgent)
+	}
+	if opts.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+opts.Token)
+	} else if opts.User != "" {
+		request.SetBasicAuth(opts.User, opts.Pass)
+	}
+	for key, value := range opts.Headers {
+		request.Header.Set(key, value)
+	}
+
+	return request, nil
+}
+
+// Performs a GET request, retrying network errors and 5xx responses with exponential backoff and jitter.
+// newRequest is called before every attempt, so each attempt gets a fresh request. Cancelling ctx stops the retries.
+func fetchWithRetry(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error), attempts int, maxElapsed time.Duration) (*http.Response, error) {
+	start := time.Now()
+	backoff := FETCH_INITIAL_BACKOFF
+
+	var lastErr error
+	attempt := 1
+	for ; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := client.Do(request)
+		// The response of an attempt that isn't handed to the caller is closed, so the connection is reused.
+		if ctx.Err() != nil {
+			if err == nil {
+				response.Body.Close()
+			}
+			return nil, fmt.Errorf("Fetch cancelled: %w", ctx.Err())
+		}
+		// Retrying won't make an untrusted certificate trusted.
+		certErr := &tls.CertificateVerificationError{}
+		if errors.As(err, &certErr) {
+			return nil, err
+		}
+		if err == nil {
+			if response.StatusCode == http.StatusOK {
+				return response, nil
+			}
+
+			response.Body.Close()
+			switch {
+			case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+				return nil, fmt.Errorf("%w: %v, the page may require authentication", ErrAccessDenied, response.Status)
+			case response.StatusCode == http.StatusNotFound:
+				return nil, fmt.Errorf("%w: %v, check the URL", ErrPageNotFound, response.Status)
+			case !isRetryableStatus(response.StatusCode):
+				return nil, fmt.Errorf("Non-okay status code: %v", response.Status)
+			}
+
+			err = fmt.Errorf("Non-okay status code: %v", response.Status)
+		}
+
+		lastErr = err
+		if attempt >= attempts {
+			break
+		}
+
+		// Sleep somewhere in [backoff/2, 3*backoff/2) so that concurrent clients don't retry in lockstep.
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		if time.Since(start)+sleep > maxElapsed {
+			break
+		}
+
+		slog.Warn("Fetch attempt failed, retrying", "attempt", attempt, "attempts", attempts, "err", err, "retry_in", sleep)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("Fetch cancelled: %w", ctx.Err())
+		}
+		backoff *= 2
+	}
+
+	return nil, fmt.Errorf("Giving up after %v attempt(s): %w", attempt, lastErr)
+}
@@ -0,0 +1,175 @@
+package scrape
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]string
+	}{
+		{"none", Options{}, map[string]string{"User-Agent": "", "Authorization": ""}},
+		{"user agent", Options{UserAgent: "HFLabsTableSync/1.2.0"}, map[string]string{"User-Agent": "HFLabsTableSync/1.2.0"}},
+		{"basic auth", Options{User: "ann", Pass: "secret"}, map[string]string{"Authorization": "Basic YW5uOnNlY3JldA=="}},
+		{"token beats basic auth", Options{User: "ann", Pass: "secret", Token: "pat"}, map[string]string{"Authorization": "Bearer pat"}},
+		{"headers", Options{Headers: map[string]string{"X-Request-Source": "sync", "Accept-Language": "ru"}}, map[string]string{"X-Request-Source": "sync", "Accept-Language": "ru"}},
+		{
+			"headers override",
+			Options{UserAgent: "HFLabsTableSync", Token: "pat", Headers: map[string]string{"User-Agent": "curl/8.0", "Authorization": "Bearer other"}},
+			map[string]string{"User-Agent": "curl/8.0", "Authorization": "Bearer other"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.URL = "https://confluence.example.com/page"
+			request, err := newRequest(context.Background(), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range test.want {
+				if got := request.Header.Get(key); got != want {
+					t.Errorf("%v = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// The headers are sent with every attempt, not only the first one.
+func TestGetTablesHeaders(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("User-Agent") != "HFLabsTableSync/1.2.0" || r.Header.Get("Authorization") != "Bearer pat" || r.Header.Get("X-Team") != "data" {
+			t.Errorf("attempt %v got headers %v", requests, r.Header)
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<table class="confluenceTable"><tr><td>a</td></tr></table>`))
+	}))
+	defer srv.Close()
+
+	opts := Options{
+		URL:        srv.URL,
+		Attempts:   2,
+		MaxElapsed: time.Minute,
+		UserAgent:  "HFLabsTableSync/1.2.0",
+		Token:      "pat",
+		Headers:    map[string]string{"X-Team": "data"},
+	}
+	tables, err := GetTables(context.Background(), srv.Client(), opts)
+	if err != nil || len(tables) != 1 || requests != 2 {
+		t.Errorf("GetTables() = %v, %v after %v requests, want the table after a retry", tables, err, requests)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the handler sends the headers and part of the body before stalling.
+		partial bool
+	}{
+		{"headers", false},
+		{"body", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.partial {
+					w.Write([]byte(`<table class="confluenceTable"><tr><td>`))
+					w.(http.Flusher).Flush()
+				}
+				<-release
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			client, err := NewClient(ClientOptions{Timeout: 100 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			_, err = GetTables(context.Background(), client, Options{URL: srv.URL, Attempts: 1, MaxElapsed: time.Minute})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("GetTables() = %v, want a context deadline error", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("GetTables() took %v, the timeout is 100ms", elapsed)
+			}
+		})
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<table class="confluenceTable"><tr><td>secure</td></tr></table>`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notPemPath := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPemPath, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      ClientOptions
+		clientErr string
+		err       string
+	}{
+		{"untrusted", ClientOptions{}, "", "certificate signed by unknown authority"},
+		{"ca cert", ClientOptions{CACert: caPath}, "", ""},
+		{"insecure", ClientOptions{InsecureSkipVerify: true}, "", ""},
+		{"missing ca cert", ClientOptions{CACert: filepath.Join(dir, "missing.pem")}, "Unable to read CA certificates", ""},
+		{"not pem", ClientOptions{CACert: notPemPath}, "No PEM certificates found", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(test.opts)
+			if test.clientErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.clientErr) {
+					t.Errorf("NewClient() = %v, want %q", err, test.clientErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A certificate error isn't retried, the backoff of the other attempts would slow the test down.
+			tables, err := GetTables(context.Background(), client, Options{URL: srv.URL, Attempts: 3, MaxElapsed: time.Minute})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("GetTables() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || len(tables) != 1 {
+				t.Errorf("GetTables() = %v, %v, want the table", tables, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// Google Docs refuses larger images.
+const IMAGE_MAX_BYTES = 50 << 20
+
+// Downloads the image the way Google Docs will, without the Confluence credentials, and checks that
+// Google Docs can insert it: a PNG, JPEG or GIF of at most IMAGE_MAX_BYTES.
+func checkImage(ctx context.Context, client *http.Client, imageURL string) error {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("Not an http(s) URL")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Non-okay status code: %v, the image may need authentication", response.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	switch mediaType {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return fmt.Errorf("Unsupported image type %q, expected PNG, JPEG or GIF", mediaType)
+	}
+
+	size, err := io.Copy(io.Discard, io.LimitReader(response.Body, IMAGE_MAX_BYTES+1))
+	if err != nil {
+		return err
+	}
+	if size > IMAGE_MAX_BYTES {
+		return fmt.Errorf("The image is larger than %v bytes", IMAGE_MAX_BYTES)
+	}
+	return nil
+}
+
+// Checks every image of the tables with checkImage, once per URL, and turns the images that fail
+// back into their alt text, as a single image Google Docs can't fetch fails the whole insertion.
+// Returns the number of dropped images.
+func CheckImages(ctx context.Context, client *http.Client, tables []Table) int {
+	checked := map[string]error{}
+	dropped := 0
+	for _, tbl := range tables {
+		for _, row := range tbl.Contents {
+			for _, cell := range row.Cells {
+				for i, span := range cell.Spans {
+					if span.Image == "" {
+						continue
+					}
+
+					err, ok := checked[span.Image]
+					if !ok {
+						err = checkImage(ctx, client, span.Image)
+						checked[span.Image] = err
+						if err != nil {
+							slog.Warn("Unable to download the image, keeping its alt text", "url", span.Image, "err", err)
+						}
+					}
+					if err != nil {
+						cell.Spans[i].Image = ""
+						dropped++
+					}
+				}
+			}
+		}
+	}
+	return dropped
+}
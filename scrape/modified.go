@@ -0,0 +1,115 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Returns the URL of the REST API content of the page, {context path}/rest/api/content/{pageId}, for a
+// viewpage.action URL, empty for the other URLs.
+func contentURL(pageURL *url.URL) string {
+	pageId := strings.TrimSpace(pageURL.Query().Get(PAGE_ID_PARAM))
+	contextPath, _, ok := strings.Cut(pageURL.Path, VIEW_PAGE_PATH)
+	if pageId == "" || !ok {
+		return ""
+	}
+
+	restURL := url.URL{
+		Scheme:   pageURL.Scheme,
+		Host:     pageURL.Host,
+		Path:     contextPath + "/rest/api/content/" + url.PathEscape(pageId),
+		RawQuery: "expand=version",
+	}
+	return restURL.String()
+}
+
+// Asks the REST API when the page was last edited, from the version of its content.
+func restLastModified(ctx context.Context, client *http.Client, opts Options, restURL string) (time.Time, error) {
+	opts.URL = restURL
+	request, err := newRequest(ctx, opts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("Non-okay status code from the REST API: %v", response.Status)
+	}
+
+	content := struct {
+		Version struct {
+			When string `json:"when"`
+		} `json:"version"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&content); err != nil {
+		return time.Time{}, fmt.Errorf("Unable to parse the page content: %v", err)
+	}
+	if content.Version.When == "" {
+		return time.Time{}, fmt.Errorf("The page content has no version time")
+	}
+	return time.Parse(time.RFC3339, content.Version.When)
+}
+
+// Takes the Last-Modified header of a HEAD request for the page, which Confluence usually doesn't send.
+func headLastModified(ctx context.Context, client *http.Client, opts Options) (time.Time, error) {
+	request, err := newRequest(ctx, opts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	request.Method = http.MethodHead
+
+	response, err := client.Do(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("Non-okay status code: %v", response.Status)
+	}
+
+	header := response.Header.Get("Last-Modified")
+	if header == "" {
+		return time.Time{}, fmt.Errorf("No Last-Modified header")
+	}
+	return http.ParseTime(header)
+}
+
+// Returns when the page at opts.URL was last modified, from the REST API for viewpage.action URLs and
+// from the Last-Modified header otherwise. It is a single cheap request without retries, and every failure
+// wraps ErrNoLastModified, so that the caller can fall back to syncing the page anyway.
+func LastModified(ctx context.Context, client *http.Client, opts Options) (time.Time, error) {
+	pageURL, err := url.Parse(opts.URL)
+	if err != nil || pageURL.Scheme == "file" || opts.HtmlFile != "" {
+		return time.Time{}, fmt.Errorf("%w: not a Confluence URL", ErrNoLastModified)
+	}
+
+	if restURL := contentURL(pageURL); restURL != "" {
+		modified, err := restLastModified(ctx, client, opts, restURL)
+		if err == nil {
+			return modified, nil
+		}
+		if ctx.Err() != nil {
+			return time.Time{}, ctx.Err()
+		}
+		return time.Time{}, fmt.Errorf("%w: %v", ErrNoLastModified, err)
+	}
+
+	modified, err := headLastModified(ctx, client, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return time.Time{}, ctx.Err()
+		}
+		return time.Time{}, fmt.Errorf("%w: %v", ErrNoLastModified, err)
+	}
+	return modified, nil
+}
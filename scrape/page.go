@@ -0,0 +1,64 @@
+package scrape
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const PAGE_ID_PARAM = "pageId"
+const VIEW_PAGE_PATH = "/pages/viewpage.action"
+
+// Checks that pageId is a Confluence page id, which is a number.
+func ValidatePageId(pageId string) error {
+	if pageId == "" {
+		return fmt.Errorf("Empty %v", PAGE_ID_PARAM)
+	}
+	if _, err := strconv.ParseUint(pageId, 10, 64); err != nil {
+		return fmt.Errorf("%v %q isn't a number", PAGE_ID_PARAM, pageId)
+	}
+	return nil
+}
+
+// Trims the pageId of a viewpage.action style URL and checks it, as Confluence answers a typo in it with
+// a page without tables rather than an error. URLs without a pageId, such as /display/SPACE/Title ones,
+// and URLs whose pageId needs no trimming are returned unchanged, since the document ids are stored by URL.
+func NormalizePageURL(rawURL string) (string, error) {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL %q: %v", rawURL, err)
+	}
+	query := pageURL.Query()
+	if pageURL.Scheme == "file" || !query.Has(PAGE_ID_PARAM) {
+		return rawURL, nil
+	}
+
+	pageId := strings.TrimSpace(query.Get(PAGE_ID_PARAM))
+	if err := ValidatePageId(pageId); err != nil {
+		return "", fmt.Errorf("Invalid URL %q: %w", rawURL, err)
+	}
+	if pageId == query.Get(PAGE_ID_PARAM) {
+		return rawURL, nil
+	}
+
+	query.Set(PAGE_ID_PARAM, pageId)
+	pageURL.RawQuery = query.Encode()
+	return pageURL.String(), nil
+}
+
+// Returns the viewpage.action URL of the page pageId of the Confluence at base, such as https://confluence.example.com.
+func PageURL(base string, pageId string) (string, error) {
+	pageId = strings.TrimSpace(pageId)
+	if err := ValidatePageId(pageId); err != nil {
+		return "", err
+	}
+
+	pageURL, err := url.Parse(base)
+	if err != nil || pageURL.Host == "" {
+		return "", fmt.Errorf("Invalid Confluence base URL %q", base)
+	}
+	pageURL.Path = strings.TrimSuffix(pageURL.Path, "/") + VIEW_PAGE_PATH
+	pageURL.RawQuery = url.Values{PAGE_ID_PARAM: {pageId}}.Encode()
+	return pageURL.String(), nil
+}
@@ -0,0 +1,364 @@
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html/charset"
+	"io"
+	"jaytaylor.com/html2text"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Converts an HTML fragment to plain text with html2text, see parse_test.go for examples. If html2text
+// fails, the tags are stripped instead, see htmlToText.
+func StripHtmlTags(s string) string {
+	text, _ := htmlToText(s)
+	return text
+}
+
+// Wrapped by the error htmlToText returns, the failure only costs the cell its layout.
+var errTextFallback = errors.New("html2text failed, stripped the tags instead")
+
+// Like StripHtmlTags, but also returns an error wrapping errTextFallback when html2text failed. The text is
+// then the text content of the parsed fragment, without html2text's line breaks, link targets and asterisks.
+// Parsing a string never fails, the fragment itself is only returned if it does anyway.
+func htmlToText(s string) (string, error) {
+	text, err := html2text.FromString(s, html2text.Options{PrettyTables: true})
+	if err == nil {
+		return text, nil
+	}
+
+	document, parseErr := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if parseErr != nil {
+		return s, fmt.Errorf("%w: %v, parsing the HTML failed too: %v", errTextFallback, err, parseErr)
+	}
+	return document.Text(), fmt.Errorf("%w: %v", errTextFallback, err)
+}
+
+// Reads a positive integer span attribute such as colspan or rowspan, defaulting to 1.
+func spanAttr(cellSelection *goquery.Selection, name string) int {
+	value, ok := cellSelection.Attr(name)
+	if !ok {
+		return 1
+	}
+
+	span, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || span < 1 {
+		return 1
+	}
+
+	return span
+}
+
+// Returns the rows of the table itself, leaving out the rows of the tables nested in its cells.
+func ownRows(tableSelection *goquery.Selection) *goquery.Selection {
+	return tableSelection.Find("tr").FilterFunction(func(i int, rowSelection *goquery.Selection) bool {
+		return rowSelection.Closest("table").IsSelection(tableSelection)
+	})
+}
+
+// Expands colspan and rowspan into a rectangular grid. The spanned cell's text is
+// put in its top-left position and the rest of the spanned positions are left empty.
+// Tables nested in a cell don't add rows or columns, they are flattened into the cell's text.
+// Also returns the number of cells whose HTML couldn't be extracted, those only keep their plain text,
+// and the number of cells html2text failed on, those had their tags stripped instead.
+func parseTable(tableSelection *goquery.Selection, opts ParseOptions) (Table, int, int) {
+	tbl := Table{}
+	failedCells := 0
+	strippedCells := 0
+
+	caption := tableSelection.ChildrenFiltered("caption").First().Text()
+	tbl.Caption = strings.Join(strings.Fields(decodeEntities(caption)), " ")
+
+	// Number of rows below the current one that are still covered by a rowspan, per column.
+	rowsCovered := map[int]int{}
+	colCnt := 0
+
+	ownRows(tableSelection).Each(func(i int, rowSelection *goquery.Selection) {
+		row := Row{}
+		fillCovered := func() {
+			for rowsCovered[len(row.Cells)] > 0 {
+				rowsCovered[len(row.Cells)]--
+				row.Cells = append(row.Cells, Cell{})
+			}
+		}
+
+		cells := rowSelection.ChildrenFiltered("td, th")
+		row.Header = cells.Length() > 0 && cells.Length() == cells.Filter("th").Length()
+
+		cells.Each(func(i int, cellSelection *goquery.Selection) {
+			fillCovered()
+
+			if cellSelection.Find("table").Length() > 0 {
+				slog.Debug("Flattening a table nested in a cell", "row", len(tbl.Contents), "col", len(row.Cells))
+			}
+
+			colspan := spanAttr(cellSelection, "colspan")
+			rowspan := spanAttr(cellSelection, "rowspan")
+			for j := 0; j < colspan; j++ {
+				if rowspan > 1 {
+					rowsCovered[len(row.Cells)] = rowspan - 1
+				}
+
+				if j == 0 {
+					cell, err := extractCell(cellSelection, opts)
+					if errors.Is(err, errTextFallback) {
+						slog.Debug("html2text failed on the cell, stripped its tags instead", "row", len(tbl.Contents), "col", len(row.Cells), "err", err)
+						strippedCells++
+					} else if err != nil {
+						slog.Debug("Failed to extract the cell HTML", "row", len(tbl.Contents), "col", len(row.Cells), "err", err)
+						failedCells++
+					}
+					if opts.PreserveAlignment {
+						cell.Align = cellAlignment(cellSelection)
+					}
+					row.Cells = append(row.Cells, cell)
+				} else {
+					row.Cells = append(row.Cells, Cell{})
+				}
+			}
+		})
+
+		// Rowspans from above may also cover the columns after the last cell of this row.
+		lastCovered := -1
+		for col, rowsLeft := range rowsCovered {
+			if rowsLeft > 0 && col > lastCovered {
+				lastCovered = col
+			}
+		}
+		for len(row.Cells) <= lastCovered {
+			if rowsCovered[len(row.Cells)] > 0 {
+				rowsCovered[len(row.Cells)]--
+			}
+			row.Cells = append(row.Cells, Cell{})
+		}
+
+		if len(row.Cells) > colCnt {
+			colCnt = len(row.Cells)
+		}
+
+		tbl.Contents = append(tbl.Contents, row)
+	})
+
+	for i := range tbl.Contents {
+		for len(tbl.Contents[i].Cells) < colCnt {
+			tbl.Contents[i].Cells = append(tbl.Contents[i].Cells, Cell{})
+		}
+	}
+
+	return tbl, failedCells, strippedCells
+}
+
+// Confluence serves its login form with a 200 status when the session is missing or the credentials are wrong.
+func looksLikeLoginPage(document *goquery.Document) bool {
+	return document.Find("#login-container, form#form-login, form[name=loginform], input[name=os_username]").Length() > 0
+}
+
+const TABLE_SELECTOR = ".confluenceTable"
+
+// Checks that selector is a valid CSS selector group.
+func ValidateSelector(selector string) error {
+	if _, err := cascadia.ParseGroup(selector); err != nil {
+		return fmt.Errorf("Invalid selector %q: %v", selector, err)
+	}
+	return nil
+}
+
+type ParseOptions struct {
+	// CSS selector of the tables, TABLE_SELECTOR if empty. Every matching table element is parsed,
+	// except the ones nested in another table, other matching elements yield tables without rows.
+	Selector string
+	// CSS selector of the elements to search for tables and headings in, the whole page if empty.
+	Container string
+	// Keep bold and italic runs of the cells as spans instead of html2text's *asterisks*.
+	PreserveFormatting bool
+	// Trim the cells and collapse whitespace runs into single spaces.
+	NormalizeCells bool
+	// When normalizing, collapse whitespace runs containing line breaks into single newlines instead.
+	KeepLineBreaks bool
+	// Turn every <br> and block element boundary into a single newline, instead of html2text's mix of
+	// single and double newlines.
+	BrAsNewline bool
+	// How to render the ul and ol lists of the cells, one of the LISTS_* constants.
+	Lists string
+	// Keep the text-align of the cells as Cell.Align.
+	PreserveAlignment bool
+	// Keep the alt text of the images in the cells with Image spans, html2text drops the images otherwise.
+	Images bool
+}
+
+// html2text's rendering, the items run together when the cells are normalized.
+const LISTS_TEXT = "text"
+
+// Every list item on its own line.
+const LISTS_LINES = "lines"
+
+// Like LISTS_LINES, also keeping the lists as List spans for real bullets.
+const LISTS_BULLETS = "bullets"
+
+// Parses every table matching opts.Selector of the HTML page read from r.
+func ParseTables(r io.Reader, opts ParseOptions) ([]Table, error) {
+	selector := opts.Selector
+	if selector == "" {
+		selector = TABLE_SELECTOR
+	}
+	if err := ValidateSelector(selector); err != nil {
+		return nil, err
+	}
+
+	document, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if document.Find(selector).Length() == 0 && looksLikeLoginPage(document) {
+		return nil, fmt.Errorf("%w: got a login page instead of tables, authentication may have failed", ErrAccessDenied)
+	}
+
+	root := document.Selection
+	if opts.Container != "" {
+		if err := ValidateSelector(opts.Container); err != nil {
+			return nil, err
+		}
+		root = document.Find(opts.Container)
+		if root.Length() == 0 {
+			return nil, fmt.Errorf("%w: no element matches the container selector %q, check it against the page HTML", ErrNoTables, opts.Container)
+		}
+	}
+
+	tables := []Table{}
+	heading := ""
+	failedCells := 0
+	strippedCells := 0
+	// Headings and tables are matched together, so they are visited in document order.
+	root.Find("h1, h2, h3, h4, " + selector).Each(func(i int, selection *goquery.Selection) {
+		// Headings and tables inside a table are part of its cells.
+		if selection.ParentsFiltered("table").Length() > 0 {
+			return
+		}
+
+		if !selection.Is(selector) {
+			heading = strings.TrimSpace(selection.Text())
+			return
+		}
+
+		if tableHtml, err := selection.Html(); err != nil {
+			slog.Debug("Failed to get the table HTML", "table", len(tables), "err", err)
+		} else {
+			slog.Debug("Table HTML", "html", tableHtml)
+		}
+
+		tbl, failed, stripped := parseTable(selection, opts)
+		tbl.Heading = heading
+		tables = append(tables, tbl)
+		failedCells += failed
+		strippedCells += stripped
+	})
+
+	if failedCells > 0 {
+		slog.Warn("Failed to extract the HTML of some cells, only their plain text is kept", "cells", failedCells)
+	}
+	if strippedCells > 0 {
+		slog.Warn("html2text failed on some cells, their tags were stripped instead and their line breaks may be lost", "cells", strippedCells)
+	}
+
+	return tables, nil
+}
+
+// Parses the tables of a saved HTML page. Relative links are left as they are, as there is no page URL to resolve them against.
+func getTablesFromFile(path string, opts ParseOptions) ([]Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open HTML file: %v", err)
+	}
+	defer f.Close()
+
+	body, err := utf8Reader(f, "")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read HTML file: %v", err)
+	}
+	return ParseTables(body, opts)
+}
+
+// Transcodes the page to UTF-8, as goquery reads everything as UTF-8 and would garble a windows-1251 page.
+// The encoding is taken from the charset of contentType, then from the BOM and <meta charset> of the page,
+// and defaults to UTF-8.
+func utf8Reader(r io.Reader, contentType string) (io.Reader, error) {
+	return charset.NewReader(r, contentType)
+}
+
+// Writes the status and headers of response to path plus ".headers" and returns path open for writing the body.
+func saveResponse(response *http.Response, path string) (*os.File, error) {
+	headers := bytes.Buffer{}
+	fmt.Fprintf(&headers, "%v %v\n", response.Proto, response.Status)
+	response.Header.Write(&headers)
+	if err := os.WriteFile(path+".headers", headers.Bytes(), 0666); err != nil {
+		return nil, fmt.Errorf("Unable to save the response headers: %v", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to save the page HTML: %v", err)
+	}
+
+	slog.Info("Saving the page HTML", "path", path, "status", response.Status)
+	return f, nil
+}
+
+// Fetches the page at opts.URL with client and parses its tables. 401 and 403 fail with ErrAccessDenied
+// and 404 with ErrPageNotFound right away, 5xx responses and network errors are retried, see fetchWithRetry.
+func GetTables(ctx context.Context, client *http.Client, opts Options) ([]Table, error) {
+	if opts.HtmlFile != "" {
+		return getTablesFromFile(opts.HtmlFile, opts.Parse)
+	}
+
+	if pageURL, err := url.Parse(opts.URL); err == nil && pageURL.Scheme == "file" {
+		return getTablesFromFile(pageURL.Path, opts.Parse)
+	}
+
+	response, err := fetchWithRetry(ctx, client, func() (*http.Request, error) {
+		return newRequest(ctx, opts)
+	}, opts.Attempts, opts.MaxElapsed)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	// A wrong URL can get a file or an API response instead of the page, which has no tables either.
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		slog.Warn("The response isn't an HTML page, check the URL", "url", response.Request.URL.String(), "content_type", mediaType)
+	}
+
+	var body io.Reader = response.Body
+	if opts.SaveHtml != "" {
+		f, err := saveResponse(response, opts.SaveHtml)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = io.TeeReader(response.Body, f)
+	}
+
+	body, err = utf8Reader(body, response.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the page: %w", err)
+	}
+
+	tables, err := ParseTables(body, opts.Parse)
+	if err != nil {
+		return nil, err
+	}
+
+	resolveLinks(tables, response.Request.URL)
+	return tables, nil
+}
@@ -0,0 +1,354 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStripHtmlTags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"<b>a<i>b</i></b>c", "*a b* c"},
+		{"<div><b>unclosed", "*unclosed*"},
+		{"a&amp;b &mdash; &#1046;", "a&b — Ж"},
+		{"a&nbsp;b", "a\u00a0b"},
+		{`<a href="http://x">link</a>`, "link ( http://x )"},
+		{"<p>a</p><p>b</p>", "a\n\nb"},
+		{"a<br>b", "a\nb"},
+		{"a<br/>b<br>", "a\nb"},
+	}
+
+	for _, test := range tests {
+		if got := StripHtmlTags(test.in); got != test.want {
+			t.Errorf("StripHtmlTags(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func testFetchOptions(url string) Options {
+	return Options{URL: url, Attempts: 3, MaxElapsed: time.Minute, Parse: ParseOptions{NormalizeCells: true}}
+}
+
+func TestGetTables(t *testing.T) {
+	page, err := os.ReadFile("testdata/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	tables, err := GetTables(context.Background(), srv.Client(), testFetchOptions(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		heading string
+		header  bool
+		rows    [][]string
+	}{
+		{"Users", true, [][]string{{"Name", "Role", "Since"}, {"Ann", "Admin", "2019"}, {"Bob", "Editor", "2021"}}},
+		{"Regions", false, [][]string{{"Moscow"}}},
+	}
+	if len(tables) != len(want) {
+		t.Fatalf("GetTables() returned %v tables, want %v", len(tables), len(want))
+	}
+	for i, tbl := range tables {
+		if tbl.Heading != want[i].heading {
+			t.Errorf("table %v heading = %q, want %q", i, tbl.Heading, want[i].heading)
+		}
+		if len(tbl.Contents) != len(want[i].rows) {
+			t.Errorf("table %v has %v rows, want %v", i, len(tbl.Contents), len(want[i].rows))
+			continue
+		}
+		for j, row := range tbl.Contents {
+			if !sameTexts(row.Texts(), want[i].rows[j]) {
+				t.Errorf("table %v row %v = %q, want %q", i, j, row.Texts(), want[i].rows[j])
+			}
+			if row.Header != (want[i].header && j == 0) {
+				t.Errorf("table %v row %v Header = %v", i, j, row.Header)
+			}
+		}
+	}
+}
+
+func TestGetTablesStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+		err      string
+		requests int
+	}{
+		{http.StatusUnauthorized, ErrAccessDenied, "401 Unauthorized, the page may require authentication", 1},
+		{http.StatusForbidden, ErrAccessDenied, "403 Forbidden, the page may require authentication", 1},
+		{http.StatusNotFound, ErrPageNotFound, "404 Not Found, check the URL", 1},
+		{http.StatusBadRequest, nil, "Non-okay status code: 400 Bad Request", 1},
+		{http.StatusBadGateway, nil, "Giving up after 2 attempt(s): Non-okay status code: 502 Bad Gateway", 2},
+		{http.StatusServiceUnavailable, nil, "Giving up after 2 attempt(s): Non-okay status code: 503 Service Unavailable", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			requests := atomic.Int32{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(test.status)
+			}))
+			defer srv.Close()
+
+			opts := testFetchOptions(srv.URL)
+			opts.Attempts = 2
+			_, err := GetTables(context.Background(), srv.Client(), opts)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("GetTables() = %v, want %q", err, test.err)
+			}
+			if test.sentinel != nil && !errors.Is(err, test.sentinel) {
+				t.Errorf("GetTables() = %v, want it to wrap %v", err, test.sentinel)
+			}
+			if int(requests.Load()) != test.requests {
+				t.Errorf("GetTables() sent %v requests, want %v", requests.Load(), test.requests)
+			}
+		})
+	}
+}
+
+func TestGetTablesRetriesUntilOk(t *testing.T) {
+	requests := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`<table class="confluenceTable"><tr><td>ok</td></tr></table>`))
+	}))
+	defer srv.Close()
+
+	tables, err := GetTables(context.Background(), srv.Client(), testFetchOptions(srv.URL))
+	if err != nil || len(tables) != 1 || requests.Load() != 2 {
+		t.Fatalf("GetTables() = %v, %v after %v requests, want 1 table after 2", tables, err, requests.Load())
+	}
+}
+
+func TestGetTablesLoginPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><form id="loginform" action="/dologin.action"><input name="os_username"><input type="password" name="os_password"></form></body></html>`))
+	}))
+	defer srv.Close()
+
+	if _, err := GetTables(context.Background(), srv.Client(), testFetchOptions(srv.URL)); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("GetTables() = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestGetTablesWindows1251(t *testing.T) {
+	// "Жук" in windows-1251.
+	cell := "\xc6\xf3\xea"
+	tests := []struct {
+		name        string
+		contentType string
+		meta        string
+	}{
+		{"header", "text/html; charset=windows-1251", ""},
+		{"meta", "text/html", `<meta charset="windows-1251">`},
+		{"http-equiv", "", `<meta http-equiv="Content-Type" content="text/html; charset=windows-1251">`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Write([]byte(`<html><head>` + test.meta + `</head><body><table class="confluenceTable"><tr><td>` + cell + `</td></tr></table></body></html>`))
+			}))
+			defer srv.Close()
+
+			tables, err := GetTables(context.Background(), srv.Client(), testFetchOptions(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tables) != 1 || tables[0].Contents[0].Cells[0].Text != "Жук" {
+				t.Errorf("GetTables() = %+v, want a Жук cell", tables)
+			}
+		})
+	}
+
+	// A saved page only has its meta tag to go by.
+	path := filepath.Join(t.TempDir(), "page.html")
+	page := `<html><head><meta charset="windows-1251"></head><body><table class="confluenceTable"><tr><td>` + cell + `</td></tr></table></body></html>`
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := GetTables(context.Background(), nil, Options{HtmlFile: path})
+	if err != nil || len(tables) != 1 || tables[0].Contents[0].Cells[0].Text != "Жук" {
+		t.Errorf("GetTables() of the file = %+v, %v, want a Жук cell", tables, err)
+	}
+}
+
+// Parses the only table of the HTML fragment.
+func parseTable1(t *testing.T, fragment string, opts ParseOptions) Table {
+	t.Helper()
+	tables, err := ParseTables(strings.NewReader(`<html><body>`+fragment+`</body></html>`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("ParseTables() returned %v tables, want 1", len(tables))
+	}
+	return tables[0]
+}
+
+func tableTexts(tbl Table) [][]string {
+	texts := make([][]string, len(tbl.Contents))
+	for i, row := range tbl.Contents {
+		texts[i] = row.Texts()
+	}
+	return texts
+}
+
+func checkTexts(t *testing.T, got Table, want [][]string) {
+	t.Helper()
+	texts := tableTexts(got)
+	if len(texts) != len(want) {
+		t.Fatalf("rows = %q, want %q", texts, want)
+	}
+	for i := range want {
+		if !sameTexts(texts[i], want[i]) {
+			t.Errorf("row %v = %q, want %q", i, texts[i], want[i])
+		}
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want [][]string
+	}{
+		{
+			"colspan",
+			`<tr><td colspan="2">a</td><td>b</td></tr><tr><td>c</td><td>d</td><td>e</td></tr>`,
+			[][]string{{"a", "", "b"}, {"c", "d", "e"}},
+		},
+		{
+			"rowspan",
+			`<tr><td rowspan="2">a</td><td>b</td></tr><tr><td>c</td></tr>`,
+			[][]string{{"a", "b"}, {"", "c"}},
+		},
+		{
+			"both",
+			`<tr><td colspan="2" rowspan="2">a</td><td>b</td></tr><tr><td>c</td></tr><tr><td>d</td><td>e</td><td>f</td></tr>`,
+			[][]string{{"a", "", "b"}, {"", "", "c"}, {"d", "e", "f"}},
+		},
+		{
+			"rowspan in the middle",
+			`<tr><td>a</td><td rowspan="2">b</td><td>c</td></tr><tr><td>d</td><td>e</td></tr>`,
+			[][]string{{"a", "b", "c"}, {"d", "", "e"}},
+		},
+		{
+			"invalid spans",
+			`<tr><td colspan="0">a</td><td rowspan="x">b</td><td colspan=" 2 ">c</td></tr>`,
+			[][]string{{"a", "b", "c", ""}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkTexts(t, parseTable1(t, `<table class="confluenceTable">`+test.html+`</table>`, ParseOptions{}), test.want)
+		})
+	}
+}
+
+func TestParseHeaderRows(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []bool
+	}{
+		{"th row", `<tr><th>a</th><th>b</th></tr><tr><td>c</td><td>d</td></tr>`, []bool{true, false}},
+		{"thead", `<thead><tr><th>a</th></tr></thead><tbody><tr><td>b</td></tr></tbody>`, []bool{true, false}},
+		{"mixed", `<tr><th>a</th><td>b</td></tr>`, []bool{false}},
+		{"th column", `<tr><th>a</th><td>b</td></tr><tr><th>c</th><td>d</td></tr>`, []bool{false, false}},
+		{"two th rows", `<tr><th>a</th></tr><tr><th>b</th></tr><tr><td>c</td></tr>`, []bool{true, true, false}},
+		{"no header", `<tr><td>a</td></tr>`, []bool{false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tbl := parseTable1(t, `<table class="confluenceTable">`+test.html+`</table>`, ParseOptions{})
+			if len(tbl.Contents) != len(test.want) {
+				t.Fatalf("got %v rows, want %v", len(tbl.Contents), len(test.want))
+			}
+			for i, row := range tbl.Contents {
+				if row.Header != test.want[i] {
+					t.Errorf("row %v Header = %v, want %v", i, row.Header, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseNestedTables(t *testing.T) {
+	page := `<h2>Outer</h2>
+<table class="confluenceTable">
+<tr><td>outer<h3>Inner heading</h3><table class="confluenceTable"><tr><td>x</td><td>y</td></tr><tr><td>z</td></tr></table></td><td>b</td></tr>
+<tr><td>c</td><td>d</td></tr>
+</table>
+<table class="confluenceTable"><tr><td>next</td></tr></table>`
+	tables, err := ParseTables(strings.NewReader(page), ParseOptions{NormalizeCells: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The nested table and heading are part of the outer cell, they add no table, row or column.
+	if len(tables) != 2 {
+		t.Fatalf("ParseTables() returned %v tables, want 2", len(tables))
+	}
+	outer := tableTexts(tables[0])
+	if len(outer) != 2 || len(outer[0]) != 2 || len(outer[1]) != 2 {
+		t.Fatalf("outer table = %q, want 2x2", outer)
+	}
+	for _, text := range []string{"outer", "Inner heading", "x", "y", "z"} {
+		if !strings.Contains(outer[0][0], text) {
+			t.Errorf("flattened cell %q doesn't contain %q", outer[0][0], text)
+		}
+	}
+	if outer[0][1] != "b" || outer[1][0] != "c" {
+		t.Errorf("outer table = %q, the other cells moved", outer)
+	}
+	if tables[0].Heading != "Outer" || tables[1].Heading != "Outer" {
+		t.Errorf("headings = %q and %q, want Outer, the heading in the cell doesn't count", tables[0].Heading, tables[1].Heading)
+	}
+}
+
+func TestParseCaption(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<table class="confluenceTable"><caption> Active
+			users &amp;amp; admins </caption><tr><td>a</td></tr></table>`, "Active users & admins"},
+		{`<table class="confluenceTable"><tr><td>a</td></tr></table>`, ""},
+		// The caption of a nested table is part of the cell.
+		{`<table class="confluenceTable"><tr><td><table><caption>inner</caption><tr><td>b</td></tr></table></td></tr></table>`, ""},
+	}
+
+	for _, test := range tests {
+		if got := parseTable1(t, test.html, ParseOptions{}).Caption; got != test.want {
+			t.Errorf("caption of %v = %q, want %q", test.html, got, test.want)
+		}
+	}
+}
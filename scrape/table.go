@@ -0,0 +1,290 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Row struct {
+	Cells []Cell
+	// Set when every cell of the row is a th element.
+	Header bool
+}
+
+type Table struct {
+	Contents []Row
+	// Text of the nearest heading preceding the table on the page, if any.
+	Heading string
+	// Text of the caption element of the table, if any.
+	Caption string
+}
+
+// Confluence pages use tables for layout too, such tables have no rows or only blank cells.
+func (tbl Table) IsEmpty() bool {
+	for _, row := range tbl.Contents {
+		for _, cell := range row.Cells {
+			if strings.TrimSpace(cell.Text) != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the plain text of every cell of the row.
+func (row Row) Texts() []string {
+	texts := make([]string, len(row.Cells))
+	for i, cell := range row.Cells {
+		texts[i] = cell.Text
+	}
+	return texts
+}
+
+func NewRow(texts ...string) Row {
+	row := Row{Cells: make([]Cell, len(texts))}
+	for i, text := range texts {
+		row.Cells[i].Text = text
+	}
+	return row
+}
+
+func sameTexts(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Merges each table whose first row repeats the first row of the previous table into that table, dropping
+// the repeated row. Confluence exports sometimes split one table in several with the same header like this.
+// The merged table keeps the heading of the first part.
+func MergeContinuationTables(tables []Table) []Table {
+	merged := []Table{}
+	for _, tbl := range tables {
+		if len(merged) > 0 && len(tbl.Contents) > 0 {
+			last := &merged[len(merged)-1]
+			if len(last.Contents) > 0 && sameTexts(last.Contents[0].Texts(), tbl.Contents[0].Texts()) {
+				last.Contents = append(last.Contents, tbl.Contents[1:]...)
+				continue
+			}
+		}
+
+		tbl.Contents = append([]Row(nil), tbl.Contents...)
+		merged = append(merged, tbl)
+	}
+	return merged
+}
+
+// Returns tbl without the rows whose texts repeat the row right above them, the first of the repeated rows is kept.
+func DedupRows(tbl Table) Table {
+	deduped := tbl
+	deduped.Contents = []Row{}
+	for i, row := range tbl.Contents {
+		if i > 0 && sameTexts(tbl.Contents[i-1].Texts(), row.Texts()) {
+			continue
+		}
+		deduped.Contents = append(deduped.Contents, row)
+	}
+	return deduped
+}
+
+// Returns the index of the first of names equal to key, ignoring case and surrounding whitespace,
+// or else key as a 0-based index into names. Returns -1 if key is neither.
+func nameOrIndex(names []string, key string) int {
+	for i, name := range names {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(key)) {
+			return i
+		}
+	}
+	if i, err := strconv.Atoi(strings.TrimSpace(key)); err == nil && i >= 0 && i < len(names) {
+		return i
+	}
+	return -1
+}
+
+// Returns the text of a cell of tables[tableIdx]. row is the text of the first cell of the row or a 0-based
+// row index, col is a header name from the first row or a 0-based column index, matched like in SelectColumns.
+func LookupCell(tables []Table, tableIdx int, row string, col string) (string, error) {
+	if tableIdx < 0 || tableIdx >= len(tables) {
+		return "", fmt.Errorf("Table #%v not found, %v table(s) found", tableIdx, len(tables))
+	}
+	tbl := tables[tableIdx]
+
+	firstCells := make([]string, len(tbl.Contents))
+	for i, r := range tbl.Contents {
+		if len(r.Cells) > 0 {
+			firstCells[i] = r.Cells[0].Text
+		}
+	}
+	rowIdx := nameOrIndex(firstCells, row)
+	if rowIdx < 0 {
+		return "", fmt.Errorf("Row %q not found in table #%v", row, tableIdx)
+	}
+
+	header := tbl.Contents[0].Texts()
+	colIdx := nameOrIndex(header, col)
+	if colIdx < 0 || colIdx >= len(tbl.Contents[rowIdx].Cells) {
+		return "", fmt.Errorf("Column %q not found in table #%v, the first row has %q", col, tableIdx, header)
+	}
+
+	return tbl.Contents[rowIdx].Cells[colIdx].Text, nil
+}
+
+// Projects tbl onto the keep columns, in the order of keep. Each column is a header name matched against
+// the first row, ignoring case and surrounding whitespace, or else a 0-based column index.
+func SelectColumns(tbl Table, keep []string) (Table, error) {
+	header := []string{}
+	if len(tbl.Contents) > 0 {
+		header = tbl.Contents[0].Texts()
+	}
+
+	indices := []int{}
+	for _, column := range keep {
+		idx := nameOrIndex(header, column)
+		if idx < 0 {
+			return Table{}, fmt.Errorf("Column %q not found, the first row has %q", column, header)
+		}
+		indices = append(indices, idx)
+	}
+
+	projected := tbl
+	projected.Contents = make([]Row, len(tbl.Contents))
+	for rowIdx, row := range tbl.Contents {
+		projected.Contents[rowIdx] = Row{Cells: make([]Cell, len(indices)), Header: row.Header}
+		for i, idx := range indices {
+			if idx < len(row.Cells) {
+				projected.Contents[rowIdx].Cells[i] = row.Cells[idx]
+			}
+		}
+	}
+	return projected, nil
+}
+
+// Returns tbl with only the data rows that have a cell matching re, or with invert, that have none.
+// The first row and the th rows are headers and always kept.
+func FilterRows(tbl Table, re *regexp.Regexp, invert bool) Table {
+	filtered := tbl
+	filtered.Contents = []Row{}
+	for i, row := range tbl.Contents {
+		if i == 0 || row.Header {
+			filtered.Contents = append(filtered.Contents, row)
+			continue
+		}
+
+		matched := false
+		for _, text := range row.Texts() {
+			if re.MatchString(text) {
+				matched = true
+				break
+			}
+		}
+		if matched != invert {
+			filtered.Contents = append(filtered.Contents, row)
+		}
+	}
+	return filtered
+}
+
+// Returns tbl with at most maxRows data rows after the header rows, the first row and the th rows
+// right below it, and a note row in italics saying how many rows were cut. maxRows <= 0 means no limit.
+// Also reports whether any rows were cut.
+func TruncateRows(tbl Table, maxRows int) (Table, bool) {
+	headerCnt := 0
+	for headerCnt < len(tbl.Contents) && (headerCnt == 0 || tbl.Contents[headerCnt].Header) {
+		headerCnt++
+	}
+	if maxRows <= 0 || len(tbl.Contents)-headerCnt <= maxRows {
+		return tbl, false
+	}
+
+	truncated := tbl
+	kept := headerCnt + maxRows
+	truncated.Contents = append([]Row(nil), tbl.Contents[:kept]...)
+
+	note := NewRow(make([]string, len(tbl.Contents[0].Cells))...)
+	if len(note.Cells) == 0 {
+		note.Cells = []Cell{{}}
+	}
+	text := fmt.Sprintf("... (truncated, %v more rows)", len(tbl.Contents)-kept)
+	note.Cells[0] = Cell{Text: text, Spans: []Span{{Start: 0, End: len([]rune(text)), Italic: true}}}
+	truncated.Contents = append(truncated.Contents, note)
+	return truncated, true
+}
+
+type Filter struct {
+	// 0-based table index, negative means any index.
+	Index int
+	// Case-insensitive substring of the table heading or caption, empty means any table.
+	Caption string
+}
+
+func SelectTables(tables []Table, filter Filter) ([]Table, error) {
+	if filter.Index < 0 && filter.Caption == "" {
+		return tables, nil
+	}
+
+	selected := []Table{}
+	for i, tbl := range tables {
+		if filter.Index >= 0 && i != filter.Index {
+			continue
+		}
+
+		caption := strings.ToLower(filter.Caption)
+		if caption != "" && !strings.Contains(strings.ToLower(tbl.Heading), caption) && !strings.Contains(strings.ToLower(tbl.Caption), caption) {
+			continue
+		}
+
+		selected = append(selected, tbl)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w: no table matches index %v and caption %q, %v table(s) found", ErrNoTables, filter.Index, filter.Caption, len(tables))
+	}
+
+	return selected, nil
+}
+
+const ELLIPSIS = "…"
+
+// Cuts the text of every cell longer than maxChars runes down to maxChars runes, the last one being
+// an ellipsis, and clips the spans to the kept text. maxChars <= 0 means no limit. The cells are
+// changed in place. Returns the number of truncated cells.
+func TruncateCells(tables []Table, maxChars int) int {
+	if maxChars <= 0 {
+		return 0
+	}
+
+	truncated := 0
+	for _, tbl := range tables {
+		for _, row := range tbl.Contents {
+			for i, cell := range row.Cells {
+				runes := []rune(cell.Text)
+				if len(runes) <= maxChars {
+					continue
+				}
+
+				kept := maxChars - 1
+				spans := []Span{}
+				for _, span := range cell.Spans {
+					if span.Start >= kept {
+						continue
+					}
+					span.End = min(span.End, kept)
+					spans = append(spans, span)
+				}
+
+				row.Cells[i].Text = string(runes[:kept]) + ELLIPSIS
+				row.Cells[i].Spans = spans
+				truncated++
+			}
+		}
+	}
+	return truncated
+}
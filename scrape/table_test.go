@@ -0,0 +1,251 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Builds a table with a row per slice of texts.
+func newTable(rows ...[]string) Table {
+	tbl := Table{Contents: make([]Row, len(rows))}
+	for i, texts := range rows {
+		tbl.Contents[i] = NewRow(texts...)
+	}
+	return tbl
+}
+
+func TestMergeContinuationTables(t *testing.T) {
+	header := []string{"Name", "Role"}
+	tests := []struct {
+		name   string
+		tables []Table
+		want   [][][]string
+	}{
+		{"none", []Table{}, [][][]string{}},
+		{
+			"continued",
+			[]Table{newTable(header, []string{"a", "1"}), newTable(header, []string{"b", "2"})},
+			[][][]string{{header, {"a", "1"}, {"b", "2"}}},
+		},
+		{
+			"continued twice",
+			[]Table{newTable(header, []string{"a", "1"}), newTable(header, []string{"b", "2"}), newTable(header)},
+			[][][]string{{header, {"a", "1"}, {"b", "2"}}},
+		},
+		{
+			"other header",
+			[]Table{newTable(header, []string{"a", "1"}), newTable([]string{"Name", "Team"}, []string{"b", "2"})},
+			[][][]string{{header, {"a", "1"}}, {{"Name", "Team"}, {"b", "2"}}},
+		},
+		{
+			"not adjacent",
+			[]Table{newTable(header), newTable([]string{"x"}), newTable(header, []string{"a", "1"})},
+			[][][]string{{header}, {{"x"}}, {header, {"a", "1"}}},
+		},
+		{
+			"empty tables",
+			[]Table{{}, {}, newTable(header)},
+			[][][]string{{}, {}, {header}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := MergeContinuationTables(test.tables)
+			if len(got) != len(test.want) {
+				t.Fatalf("MergeContinuationTables() returned %v tables, want %v", len(got), len(test.want))
+			}
+			for i := range got {
+				checkTexts(t, got[i], test.want[i])
+			}
+		})
+	}
+
+	// The first part keeps its heading and the tables passed in are left as they are.
+	first := newTable(header, []string{"a", "1"})
+	first.Heading = "Users"
+	second := newTable(header, []string{"b", "2"})
+	second.Heading = "Users, continued"
+	merged := MergeContinuationTables([]Table{first, second})
+	if merged[0].Heading != "Users" || len(first.Contents) != 2 {
+		t.Errorf("merged heading %q and %v rows left in the first part, want Users and 2", merged[0].Heading, len(first.Contents))
+	}
+}
+
+func TestTruncateCells(t *testing.T) {
+	tests := []struct {
+		name     string
+		cell     Cell
+		maxChars int
+		want     Cell
+		cut      int
+	}{
+		{"short", Cell{Text: "abc"}, 3, Cell{Text: "abc"}, 0},
+		{"long", Cell{Text: "abcdef"}, 4, Cell{Text: "abc…"}, 1},
+		{"cyrillic", Cell{Text: "Жукжук"}, 3, Cell{Text: "Жу…"}, 1},
+		{"no limit", Cell{Text: "abcdef"}, 0, Cell{Text: "abcdef"}, 0},
+		{"alignment kept", Cell{Text: "abcdef", Align: ALIGN_END}, 4, Cell{Text: "abc…", Align: ALIGN_END}, 1},
+		{
+			"spans clipped",
+			Cell{Text: "bold link", Spans: []Span{{Start: 0, End: 4, Bold: true}, {Start: 5, End: 9, Link: "x"}}},
+			6,
+			Cell{Text: "bold …", Spans: []Span{{Start: 0, End: 4, Bold: true}}},
+			1,
+		},
+		{
+			"span cut",
+			Cell{Text: "linked text", Spans: []Span{{Start: 0, End: 11, Link: "x"}}},
+			5,
+			Cell{Text: "link…", Spans: []Span{{Start: 0, End: 4, Link: "x"}}},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tables := []Table{{Contents: []Row{{Cells: []Cell{test.cell}}}}}
+			if cut := TruncateCells(tables, test.maxChars); cut != test.cut {
+				t.Errorf("TruncateCells() = %v, want %v", cut, test.cut)
+			}
+
+			got := tables[0].Contents[0].Cells[0]
+			if got.Text != test.want.Text || got.Align != test.want.Align || len(got.Spans) != len(test.want.Spans) {
+				t.Fatalf("cell = %+v, want %+v", got, test.want)
+			}
+			for i := range got.Spans {
+				if got.Spans[i] != test.want.Spans[i] {
+					t.Errorf("span %v = %+v, want %+v", i, got.Spans[i], test.want.Spans[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDedupRows(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+		want [][]string
+	}{
+		{"none", [][]string{}, [][]string{}},
+		{"repeated", [][]string{{"a", "1"}, {"a", "1"}, {"b", "2"}, {"b", "2"}, {"b", "2"}}, [][]string{{"a", "1"}, {"b", "2"}}},
+		{"not adjacent", [][]string{{"a"}, {"b"}, {"a"}}, [][]string{{"a"}, {"b"}, {"a"}}},
+		{"one cell differs", [][]string{{"a", "1"}, {"a", "2"}}, [][]string{{"a", "1"}, {"a", "2"}}},
+		{"shorter row", [][]string{{"a", ""}, {"a"}}, [][]string{{"a", ""}, {"a"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tbl := newTable(test.rows...)
+			checkTexts(t, DedupRows(tbl), test.want)
+			if len(tbl.Contents) != len(test.rows) {
+				t.Errorf("DedupRows() changed the rows of its argument")
+			}
+		})
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	tbl := newTable([]string{"Name", " Role ", "Since"}, []string{"Ann", "Admin", "2019"}, []string{"Bob"})
+	tbl.Contents[0].Header = true
+	tests := []struct {
+		name string
+		keep []string
+		want [][]string
+		err  string
+	}{
+		{"by name", []string{"Since", "name"}, [][]string{{"Since", "Name"}, {"2019", "Ann"}, {"", "Bob"}}, ""},
+		{"by index", []string{"1"}, [][]string{{" Role "}, {"Admin"}, {""}}, ""},
+		{"trimmed", []string{" role"}, [][]string{{" Role "}, {"Admin"}, {""}}, ""},
+		{"repeated", []string{"0", "Name"}, [][]string{{"Name", "Name"}, {"Ann", "Ann"}, {"Bob", "Bob"}}, ""},
+		{"unknown name", []string{"Team"}, nil, `Column "Team" not found`},
+		{"index out of range", []string{"3"}, nil, `Column "3" not found`},
+		{"negative index", []string{"-1"}, nil, `Column "-1" not found`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := SelectColumns(tbl, test.keep)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("SelectColumns() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTexts(t, got, test.want)
+			if !got.Contents[0].Header {
+				t.Errorf("the header row lost its Header flag")
+			}
+		})
+	}
+}
+
+func TestFilterRows(t *testing.T) {
+	tbl := newTable([]string{"Name", "Role"}, []string{"Ann", "Admin"}, []string{"Bob", "Editor"}, []string{"Sub", "Header"}, []string{"Eve", "admin"})
+	tbl.Contents[3].Header = true
+	tests := []struct {
+		pattern string
+		invert  bool
+		want    [][]string
+	}{
+		{"Admin", false, [][]string{{"Name", "Role"}, {"Ann", "Admin"}, {"Sub", "Header"}}},
+		{"(?i)admin", false, [][]string{{"Name", "Role"}, {"Ann", "Admin"}, {"Sub", "Header"}, {"Eve", "admin"}}},
+		{"(?i)admin", true, [][]string{{"Name", "Role"}, {"Bob", "Editor"}, {"Sub", "Header"}}},
+		{"^B", false, [][]string{{"Name", "Role"}, {"Bob", "Editor"}, {"Sub", "Header"}}},
+		{"nothing", false, [][]string{{"Name", "Role"}, {"Sub", "Header"}}},
+		{"", true, [][]string{{"Name", "Role"}, {"Sub", "Header"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v invert %v", test.pattern, test.invert), func(t *testing.T) {
+			checkTexts(t, FilterRows(tbl, regexp.MustCompile(test.pattern), test.invert), test.want)
+		})
+	}
+}
+
+func TestLookupCell(t *testing.T) {
+	tables := []Table{
+		newTable([]string{"Name", "Role"}, []string{"Ann", "Admin"}, []string{"Bob", "Editor"}, []string{"Eve"}),
+		newTable([]string{"Region", "Code"}, []string{"Moscow", "77"}),
+	}
+	tests := []struct {
+		name  string
+		table int
+		row   string
+		col   string
+		want  string
+		err   string
+	}{
+		{"by names", 0, "Bob", "Role", "Editor", ""},
+		{"ignoring case and whitespace", 0, " ann ", "role", "Admin", ""},
+		{"by indices", 0, "1", "0", "Ann", ""},
+		{"header row", 0, "Name", "Role", "Role", ""},
+		{"second table", 1, "Moscow", "Code", "77", ""},
+		{"table out of range", 2, "Ann", "Role", "", "Table #2 not found, 2 table(s) found"},
+		{"negative table", -1, "Ann", "Role", "", "Table #-1 not found"},
+		{"row not found", 0, "Kim", "Role", "", `Row "Kim" not found in table #0`},
+		{"row index out of range", 0, "4", "Role", "", `Row "4" not found`},
+		{"column not found", 0, "Ann", "Team", "", `Column "Team" not found in table #0`},
+		{"short row", 0, "Eve", "Role", "", `Column "Role" not found`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := LookupCell(tables, test.table, test.row, test.col)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("LookupCell() = %q, %v, want %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("LookupCell() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package syncer
+
+import "errors"
+
+// The errors of the steps of a Sync wrap one of these, so that callers can tell which step failed
+// with errors.Is. The errors of the scrape and gdocs packages are wrapped too.
+var (
+	// Authorizing, opening or creating the document failed.
+	ErrDocument = errors.New("Document step failed")
+	// Fetching or parsing the page failed, or it has no tables.
+	ErrScrape = errors.New("Scrape step failed")
+	// Clearing the document or inserting the tables failed.
+	ErrWrite = errors.New("Write step failed")
+)
+
+// Marks err as the failure of step without changing its message.
+type stepErr struct {
+	step error
+	err  error
+}
+
+func (e *stepErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepErr) Unwrap() error {
+	return e.err
+}
+
+func (e *stepErr) Is(target error) bool {
+	return target == e.step
+}
+
+func stepError(step error, err error) error {
+	return &stepErr{step: step, err: err}
+}
@@ -0,0 +1,71 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"os"
+	"sync"
+)
+
+// Hashes the tables together with the insert options and toc, so that changing how the tables
+// are laid out also counts as a change. Only whether there is a timestamp line counts, not its time.
+func tablesHash(tables []scrape.Table, opts []gdocs.InsertOptions, toc bool) (string, error) {
+	b, err := json.Marshal(struct {
+		Tables    []scrape.Table
+		Options   []gdocs.InsertOptions
+		Toc       bool
+		Timestamp bool
+	}{tables, opts, toc, len(opts) > 0 && opts[0].Preamble != ""})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Reads the hashes of the last written tables by document id, a missing file means no hashes.
+func loadHashes(path string) (map[string]string, error) {
+	hashes := map[string]string{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read hash file: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &hashes); err != nil {
+		return nil, fmt.Errorf("Unable to parse hash file %v: %v", path, err)
+	}
+	return hashes, nil
+}
+
+// Guards the read-modify-write of the hash file by concurrent jobs.
+var hashMu sync.Mutex
+
+func saveHash(path string, docId string, hash string) error {
+	hashMu.Lock()
+	defer hashMu.Unlock()
+
+	hashes, err := loadHashes(path)
+	if err != nil {
+		return err
+	}
+
+	hashes[docId] = hash
+	b, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, b, 0666); err != nil {
+		return fmt.Errorf("Unable to write hash file: %v", err)
+	}
+	return nil
+}
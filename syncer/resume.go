@@ -0,0 +1,147 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"os"
+	"sync"
+)
+
+// A table inserted by a -resume run, one JSON object per line of the append-only resume log.
+type resumeEntry struct {
+	// Hash of the tables and options of the run, see tablesHash, so that a changed page starts over.
+	RunId      string `json:"run_id"`
+	DocumentId string `json:"document_id"`
+	Table      int    `json:"table"`
+}
+
+// Guards the resume log, concurrent jobs append to the same file.
+var resumeMu sync.Mutex
+
+func readResumeLog(path string) ([]resumeEntry, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read resume log: %v", err)
+	}
+
+	entries := []resumeEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		entry := resumeEntry{}
+		// A crash in the middle of an append leaves a truncated last line, the table is inserted again.
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Returns the indices of the tables the run already inserted into the document, a missing log means none.
+func loadResumeLog(path string, docId string, runId string) (map[int]bool, error) {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+
+	entries, err := readResumeLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	done := map[int]bool{}
+	for _, entry := range entries {
+		if entry.DocumentId == docId && entry.RunId == runId {
+			done[entry.Table] = true
+		}
+	}
+	return done, nil
+}
+
+func appendResumeLog(path string, entry resumeEntry) error {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("Unable to open resume log: %v", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to write resume log: %v", err)
+	}
+	return f.Close()
+}
+
+// Drops the entries of the document once all its tables are inserted, and the log itself when it is empty.
+func clearResumeLog(path string, docId string) error {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+
+	entries, err := readResumeLog(path)
+	if err != nil {
+		return err
+	}
+
+	b := bytes.Buffer{}
+	for _, entry := range entries {
+		if entry.DocumentId == docId {
+			continue
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b.Write(append(line, '\n'))
+	}
+
+	if b.Len() == 0 {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	} else {
+		err = os.WriteFile(path, b.Bytes(), 0666)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to clear resume log: %v", err)
+	}
+	return nil
+}
+
+// Inserts the tables one by one, skipping the ones in done, and logs every inserted table, so that a rerun
+// can go on after the last one. It takes 3 API calls per table instead of 3 in all. It stops at the first
+// failure, as inserting the later tables would put them out of order. Returns the number of inserted tables.
+func (s *Syncer) insertResumable(ctx context.Context, srv *docs.Service, doc *docs.Document, tables []scrape.Table, opts []gdocs.InsertOptions, runId string, done map[int]bool) (int, error) {
+	inserted := 0
+	for i := range tables {
+		if done[i] {
+			continue
+		}
+
+		n, err := gdocs.InsertTablesToDocument(ctx, doc.DocumentId, srv, tables[i:i+1], opts[i:i+1])
+		inserted += n
+		if err != nil {
+			return inserted, fmt.Errorf("Stopped at table #%v, a rerun with resume goes on from it: %w", i, err)
+		}
+
+		// Without the entry a rerun would insert the table twice.
+		if err := appendResumeLog(s.opts.ResumePath, resumeEntry{RunId: runId, DocumentId: doc.DocumentId, Table: i}); err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
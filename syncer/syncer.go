@@ -0,0 +1,277 @@
+// Package syncer copies the tables of a Confluence page into a Google Docs document. The command runs
+// every Google Docs job through it, and programs can embed it instead of running the binary:
+//
+//	s, err := syncer.New(syncer.DefaultOptions())
+//	if err != nil {
+//		return err
+//	}
+//	result, err := s.Sync(ctx)
+//
+// Sync is OpenDocument, Scrape and Write in a row. The command calls the steps itself, to scrape a page
+// once for several documents and to select, filter and export the tables between Scrape and Write.
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"log/slog"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// Defaults of the options, the command's flags default to the same values.
+const CONFLUENCE_URL = "https://confluence.hflabs.ru/pages/viewpage.action?pageId=1181220999"
+const CREDENTIALS_PATH = "credentials.json"
+const DOCUMENT_ID_PATH = "document_id.txt"
+const TOKEN_PATH = "token.json"
+const HASH_PATH = "tables_hash.json"
+const RESUME_PATH = "resume.log"
+const DOCUMENT_TITLE = "HFLabsTestTaskTableDocument"
+const FETCH_ATTEMPTS = 5
+const FETCH_MAX_ELAPSED = 2 * time.Minute
+const FETCH_TIMEOUT = 30 * time.Second
+
+// The command adds its version, such as HFLabsTableSync/1.2.0.
+const USER_AGENT = "HFLabsTableSync"
+
+// The Docs API allows 60 write requests per minute per user.
+const DOCS_QPS = 1.0
+
+type Options struct {
+	// Page to scrape, CONFLUENCE_URL by default. Scrape.URL is ignored.
+	URL string
+	// Id of an existing document to write to. If empty, the document stored for URL in DocumentIdPath
+	// (DOCUMENT_ID_PATH by default) is used, or a new one is created and stored.
+	DocumentId     string
+	DocumentIdPath string
+	// Title of a new document, DOCUMENT_TITLE by default. A text/template with {{.Date}}, {{.Time}},
+	// {{.URL}} and {{.Tables}}, the time is in Location, the local one if nil.
+	Title    string
+	Location *time.Location
+	// Also rename an existing document to Title, Auth needs DriveAccess.
+	Rename bool
+	// Create new documents in this Drive folder, Auth needs FullDriveAccess.
+	DriveFolderId string
+
+	// Add the tables after the content of the document instead of clearing it first.
+	Append bool
+	// Replace the occurrences of this text in the document with the tables instead of clearing it.
+	Placeholder string
+	// Clear the document even if the page has no tables, ErrNoTables is returned otherwise.
+	AllowEmpty bool
+	// Called before clearing a stored document that has content, with its length, to ask the user.
+	// An error leaves the document as it is. If nil the document is cleared without asking.
+	Confirm func(doc *docs.Document, length int64) error
+
+	// Insert the heading preceding each table on the page as a section heading above it, on by default.
+	PageHeadings bool
+	// Insert a "Last updated" line with the time and the source above the tables.
+	Timestamp bool
+	// Insert a list of links to the headings at the beginning of the document.
+	Toc bool
+
+	// File of the hashes of the tables last written to each document, HASH_PATH by default.
+	// Unchanged tables aren't written again unless Force is set. Empty writes them every time.
+	HashPath string
+	Force    bool
+	// Insert the tables one by one and log them in ResumePath, RESUME_PATH by default, so that a rerun
+	// after a failure skips the inserted ones.
+	Resume     bool
+	ResumePath string
+
+	// Fetching and parsing the page, FETCH_ATTEMPTS, FETCH_MAX_ELAPSED and USER_AGENT by default.
+	// User, Pass and Token are the Confluence credentials.
+	Scrape scrape.Options
+	// Also scrape the pages linked from the page.
+	Crawl scrape.CrawlOptions
+	// The HTTP client of Confluence, with a FETCH_TIMEOUT timeout by default. HTTPClient is used instead
+	// if set, to share one between syncers.
+	Client     scrape.ClientOptions
+	HTTPClient *http.Client
+	// Google credentials, CREDENTIALS_PATH and TOKEN_PATH by default, and the DOCS_QPS rate of the Docs API.
+	Auth gdocs.AuthOptions
+	// Layout of every table, Heading has {n} replaced by the table number. Separator, Section, Caption
+	// and Preamble are set per table.
+	Insert gdocs.InsertOptions
+
+	// Progress messages, slog.Default() if nil.
+	Logger *slog.Logger
+}
+
+// Returns the options the command runs with when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		URL:            CONFLUENCE_URL,
+		DocumentIdPath: DOCUMENT_ID_PATH,
+		Title:          DOCUMENT_TITLE,
+		PageHeadings:   true,
+		HashPath:       HASH_PATH,
+		ResumePath:     RESUME_PATH,
+		Scrape: scrape.Options{
+			Attempts:   FETCH_ATTEMPTS,
+			MaxElapsed: FETCH_MAX_ELAPSED,
+			UserAgent:  USER_AGENT,
+			Parse:      scrape.ParseOptions{Selector: scrape.TABLE_SELECTOR, NormalizeCells: true, Lists: scrape.LISTS_TEXT},
+		},
+		Crawl:  scrape.CrawlOptions{Selector: scrape.CRAWL_SELECTOR},
+		Client: scrape.ClientOptions{Timeout: FETCH_TIMEOUT},
+		Auth: gdocs.AuthOptions{
+			CredentialsPath: CREDENTIALS_PATH,
+			TokenPath:       TOKEN_PATH,
+			Mode:            gdocs.AUTH_MODE_AUTO,
+			QPS:             DOCS_QPS,
+		},
+	}
+}
+
+type Syncer struct {
+	opts   Options
+	client *http.Client
+}
+
+// What a Sync or a Write did.
+type Result struct {
+	DocumentId string
+	// The document was created by this Sync.
+	Created bool
+	// The tables are the same as the last time they were written, so the document was left as it is.
+	Unchanged bool
+	// Tables found on the page, and the ones written to the document, the empty layout tables left out.
+	Found    int
+	Inserted int
+	// Entries of the table of contents inserted with Toc.
+	TocEntries int
+}
+
+// Checks opts and builds the Confluence client, nothing is fetched or authorized until Sync.
+func New(opts Options) (*Syncer, error) {
+	if opts.URL == "" && opts.Scrape.HtmlFile == "" {
+		return nil, fmt.Errorf("Either URL or Scrape.HtmlFile is required")
+	}
+	if opts.DocumentId == "" && opts.DocumentIdPath == "" {
+		return nil, fmt.Errorf("Either DocumentId or DocumentIdPath is required")
+	}
+	if opts.Title == "" {
+		return nil, fmt.Errorf("Title is required")
+	}
+	if _, err := template.New("title").Parse(opts.Title); err != nil {
+		return nil, fmt.Errorf("Invalid Title template: %v", err)
+	}
+	if opts.Scrape.Attempts < 1 {
+		return nil, fmt.Errorf("Scrape.Attempts must be at least 1, got %v", opts.Scrape.Attempts)
+	}
+	if opts.Scrape.MaxElapsed <= 0 {
+		return nil, fmt.Errorf("Scrape.MaxElapsed must be positive, got %v", opts.Scrape.MaxElapsed)
+	}
+	if opts.Resume && opts.ResumePath == "" {
+		return nil, fmt.Errorf("Resume needs a ResumePath")
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	opts.Scrape.URL = opts.URL
+
+	client := opts.HTTPClient
+	if client == nil {
+		var err error
+		client, err = scrape.NewClient(opts.Client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Syncer{opts: opts, client: client}, nil
+}
+
+// Authorizes with Google, opens the document, scrapes the page and writes its tables to the document,
+// in that order, so that a bad document id or missing access fails before the page is fetched.
+// Like the command, it fails without touching the document when the page has no tables, see AllowEmpty.
+func (s *Syncer) Sync(ctx context.Context) (Result, error) {
+	srv, err := gdocs.GetService(ctx, s.opts.Auth)
+	if err != nil {
+		return Result{}, stepError(ErrDocument, fmt.Errorf("Failed to get service: %w", err))
+	}
+
+	doc, err := s.OpenDocument(ctx, srv)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tables, err := s.Scrape(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return s.Write(ctx, srv, doc, tables)
+}
+
+// Opens the given or stored document, nil if none is stored yet, Write creates it then.
+func (s *Syncer) OpenDocument(ctx context.Context, srv *docs.Service) (*docs.Document, error) {
+	var doc *docs.Document
+	var err error
+	// A given document is never replaced with a new one, so it has to exist.
+	if s.opts.DocumentId != "" {
+		doc, err = gdocs.ValidateDocument(ctx, s.opts.DocumentId, srv)
+	} else {
+		doc, err = gdocs.OpenDocument(ctx, srv, s.opts.DocumentIdPath, s.opts.URL)
+	}
+	if err != nil {
+		return nil, stepError(ErrDocument, fmt.Errorf("Failed to get document: %w", err))
+	}
+	if doc != nil {
+		s.opts.Logger.Info("Using document", "document_id", doc.DocumentId)
+	}
+	return doc, nil
+}
+
+// Scrapes the tables of the page, and with Crawl.Depth also of the pages linked from it, in crawl order.
+// Returns ErrNoTables if there are none, unless AllowEmpty is set.
+func (s *Syncer) Scrape(ctx context.Context) ([]scrape.Table, error) {
+	tables, err := s.scrape(ctx)
+	if err != nil {
+		return nil, stepError(ErrScrape, fmt.Errorf("Failed to get tables: %w", err))
+	}
+	s.opts.Logger.Info("Found tables", "count", len(tables))
+
+	// Going on would clear the document and leave it empty.
+	if len(tables) == 0 && !s.opts.AllowEmpty {
+		return nil, stepError(ErrScrape, fmt.Errorf("%w found on the page, the URL may be wrong or the page structure may have changed, set AllowEmpty to clear the document anyway", scrape.ErrNoTables))
+	}
+	return tables, nil
+}
+
+func (s *Syncer) scrape(ctx context.Context) ([]scrape.Table, error) {
+	opts := s.opts.Scrape
+	if s.opts.Crawl.Depth == 0 {
+		return scrape.GetTables(ctx, s.client, opts)
+	}
+
+	pages, err := scrape.CrawlPages(ctx, s.client, opts, s.opts.Crawl)
+	if err != nil {
+		return nil, err
+	}
+	s.opts.Logger.Info("Crawled pages", "pages", len(pages), "depth", s.opts.Crawl.Depth)
+
+	tables := []scrape.Table{}
+	for _, page := range pages {
+		opts.URL = page
+		pageTables, err := scrape.GetTables(ctx, s.client, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", page, err)
+		}
+		s.opts.Logger.Debug("Found tables on a crawled page", "url", page, "count", len(pageTables))
+		tables = append(tables, pageTables...)
+	}
+	return tables, nil
+}
+
+// The logger of the progress messages, Options.Logger or slog.Default().
+func (s *Syncer) Logger() *slog.Logger {
+	return s.opts.Logger
+}
@@ -0,0 +1,296 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/internal/docstest"
+	"hflabstesttask/scrape"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testOptions(t *testing.T) Options {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.URL = "https://confluence.example.com/page"
+	opts.DocumentIdPath = filepath.Join(dir, DOCUMENT_ID_PATH)
+	opts.HashPath = filepath.Join(dir, HASH_PATH)
+	opts.ResumePath = filepath.Join(dir, RESUME_PATH)
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return opts
+}
+
+func testTables() []scrape.Table {
+	return []scrape.Table{
+		{Heading: "Users", Contents: []scrape.Row{scrape.NewRow("Name", "Role"), scrape.NewRow("Ann", "Admin")}},
+		// A layout table, which isn't written.
+		{Contents: []scrape.Row{scrape.NewRow(" ", "")}},
+	}
+}
+
+func newTestSyncer(t *testing.T, opts Options) *Syncer {
+	s, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Returns the texts of the cells of the tables of the document, row by row.
+func cellTexts(doc *docstest.Document) [][]string {
+	rows := [][]string{}
+	for _, element := range doc.Elements {
+		for _, row := range element.Cells {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(opts *Options)
+		err    string
+	}{
+		{"defaults", func(opts *Options) {}, ""},
+		{"no source", func(opts *Options) { opts.URL = "" }, "Either URL or Scrape.HtmlFile is required"},
+		{"html file", func(opts *Options) { opts.URL, opts.Scrape.HtmlFile = "", "page.html" }, ""},
+		{"no document", func(opts *Options) { opts.DocumentIdPath = "" }, "Either DocumentId or DocumentIdPath is required"},
+		{"no title", func(opts *Options) { opts.Title = "" }, "Title is required"},
+		{"bad title", func(opts *Options) { opts.Title = "{{.Date" }, "Invalid Title template"},
+		{"no attempts", func(opts *Options) { opts.Scrape.Attempts = 0 }, "Scrape.Attempts must be at least 1"},
+		{"no max elapsed", func(opts *Options) { opts.Scrape.MaxElapsed = 0 }, "Scrape.MaxElapsed must be positive"},
+		{"resume without path", func(opts *Options) { opts.Resume, opts.ResumePath = true, "" }, "Resume needs a ResumePath"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			test.modify(&opts)
+			_, err := New(opts)
+			if test.err == "" && err != nil {
+				t.Fatalf("New() = %v, want no error", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("New() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestTableOptions(t *testing.T) {
+	tbl := scrape.Table{Heading: "Users", Caption: "All users"}
+	tests := []struct {
+		name         string
+		append       bool
+		pageHeadings bool
+		index        int
+		want         string
+	}{
+		{"first", false, true, 0, "separator=false section=Users heading=Table 1"},
+		{"second", false, true, 1, "separator=true section=Users heading=Table 2"},
+		{"first appended", true, true, 0, "separator=true section=Users heading=Table 1"},
+		{"no page headings", false, false, 2, "separator=true section= heading=Table 3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := testOptions(t)
+			opts.Append = test.append
+			opts.PageHeadings = test.pageHeadings
+			opts.Insert.Heading = "Table {n}"
+			opts.Insert.Preamble = "left over"
+			got := newTestSyncer(t, opts).tableOptions(test.index, tbl)
+
+			if s := fmt.Sprintf("separator=%v section=%v heading=%v", got.Separator, got.Section, got.Heading); s != test.want {
+				t.Errorf("tableOptions() = %v, want %v", s, test.want)
+			}
+			if got.Caption != tbl.Caption || got.Preamble != "" {
+				t.Errorf("tableOptions() caption %q preamble %q, want %q and none", got.Caption, got.Preamble, tbl.Caption)
+			}
+			if got.Logger != opts.Logger {
+				t.Errorf("tableOptions() doesn't log through Logger")
+			}
+		})
+	}
+}
+
+func TestRenderTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Tables", "Tables"},
+		{"{{.Tables}} tables of {{.URL}}", "3 tables of https://confluence.example.com/page"},
+	}
+
+	for _, test := range tests {
+		opts := testOptions(t)
+		opts.Title = test.title
+		got, err := newTestSyncer(t, opts).renderTitle(3)
+		if err != nil || got != test.want {
+			t.Errorf("renderTitle(%q) = %q, %v, want %q", test.title, got, err, test.want)
+		}
+	}
+}
+
+func TestStepError(t *testing.T) {
+	cause := errors.New("Boom")
+	err := stepError(ErrWrite, cause)
+
+	if err.Error() != "Boom" {
+		t.Errorf("Error() = %q, want the message of the cause", err.Error())
+	}
+	if !errors.Is(err, ErrWrite) || !errors.Is(err, cause) {
+		t.Errorf("errors.Is() doesn't match the step or the cause of %v", err)
+	}
+	if errors.Is(err, ErrScrape) || errors.Is(err, ErrDocument) {
+		t.Errorf("errors.Is() matches another step")
+	}
+}
+
+func TestWriteCreatesDocument(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	s := newTestSyncer(t, testOptions(t))
+
+	result, err := s.Write(context.Background(), srv, nil, testTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Created || result.Found != 2 || result.Inserted != 1 || result.Unchanged {
+		t.Fatalf("Write() = %+v, want a created document with 1 of 2 tables", result)
+	}
+
+	doc := fake.Document(result.DocumentId)
+	if doc.Title != DOCUMENT_TITLE {
+		t.Errorf("title = %q, want %q", doc.Title, DOCUMENT_TITLE)
+	}
+	want := [][]string{{"Name\n", "Role\n"}, {"Ann\n", "Admin\n"}}
+	if got := cellTexts(doc); !equalRows(got, want) {
+		t.Errorf("cells = %q, want %q", got, want)
+	}
+
+	// The document id is stored for the URL, so the next run opens the same document.
+	opened, err := s.OpenDocument(context.Background(), srv)
+	if err != nil || opened == nil || opened.DocumentId != result.DocumentId {
+		t.Fatalf("OpenDocument() = %v, %v, want %v", opened, err, result.DocumentId)
+	}
+}
+
+func TestWriteSkipsUnchangedTables(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	s := newTestSyncer(t, testOptions(t))
+	ctx := context.Background()
+
+	first, err := s.Write(ctx, srv, nil, testTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := s.OpenDocument(ctx, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := len(fake.Batches())
+
+	second, err := s.Write(ctx, srv, doc, testTables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Unchanged || second.Created || second.DocumentId != first.DocumentId {
+		t.Errorf("Write() = %+v, want the unchanged document %v", second, first.DocumentId)
+	}
+	if len(fake.Batches()) != batches {
+		t.Errorf("Write() of unchanged tables sent %v batch updates", len(fake.Batches())-batches)
+	}
+}
+
+func TestWriteConfirmsClear(t *testing.T) {
+	fake := docstest.NewServer(t)
+	srv := fake.Service(t)
+	ctx := context.Background()
+	docId := fake.AddDocument("Someone's document", docstest.Element{Text: "Notes\n"})
+
+	refused := errors.New("Refused")
+	asked := int64(-1)
+	opts := testOptions(t)
+	opts.DocumentId = docId
+	opts.Confirm = func(doc *docs.Document, length int64) error {
+		asked = length
+		return refused
+	}
+	s := newTestSyncer(t, opts)
+
+	doc, err := s.OpenDocument(ctx, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write(ctx, srv, doc, testTables()); !errors.Is(err, refused) {
+		t.Fatalf("Write() = %v, want %v", err, refused)
+	}
+	if asked != int64(len("Notes")) {
+		t.Errorf("Confirm() got length %v, want %v", asked, len("Notes"))
+	}
+	if got := fake.Document(docId).Elements; len(got) != 1 || got[0].Text != "Notes\n" {
+		t.Errorf("document = %q, want it left as it is", got)
+	}
+
+	// A confirmed clear replaces the content with the tables.
+	opts.Confirm = func(doc *docs.Document, length int64) error { return nil }
+	s = newTestSyncer(t, opts)
+	if _, err := s.Write(ctx, srv, doc, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	for _, element := range fake.Document(docId).Elements {
+		if element.Text == "Notes\n" {
+			t.Errorf("document still has the old content after the clear")
+		}
+	}
+}
+
+func TestScrapeWithoutTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(path, []byte("<html><body><p>No tables</p></body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		allowEmpty bool
+		wantErr    bool
+	}{
+		{false, true},
+		{true, false},
+	}
+	for _, test := range tests {
+		opts := testOptions(t)
+		opts.Scrape.HtmlFile = path
+		opts.AllowEmpty = test.allowEmpty
+		tables, err := newTestSyncer(t, opts).Scrape(context.Background())
+		if test.wantErr && (!errors.Is(err, ErrScrape) || !errors.Is(err, scrape.ErrNoTables)) {
+			t.Errorf("Scrape() with AllowEmpty %v = %v, want ErrScrape and ErrNoTables", test.allowEmpty, err)
+		}
+		if !test.wantErr && (err != nil || len(tables) != 0) {
+			t.Errorf("Scrape() with AllowEmpty %v = %v, %v, want no tables", test.allowEmpty, tables, err)
+		}
+	}
+}
+
+func equalRows(a [][]string, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.Join(a[i], "\x00") != strings.Join(b[i], "\x00") || len(a[i]) != len(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,290 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/docs/v1"
+	"hflabstesttask/gdocs"
+	"hflabstesttask/scrape"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Fields available to the Title template.
+type titleData struct {
+	Now time.Time
+	// Now formatted as 2006-01-02 and 15:04.
+	Date string
+	Time string
+	// URL of the scraped page, or the path of the HTML file.
+	URL    string
+	Tables int
+}
+
+// The URL of the scraped page, or the path of the HTML file.
+func (s *Syncer) source() string {
+	if s.opts.Scrape.HtmlFile != "" {
+		return s.opts.Scrape.HtmlFile
+	}
+	return s.opts.URL
+}
+
+// The line inserted above the tables when Timestamp is on.
+func (s *Syncer) timestampLine() string {
+	return fmt.Sprintf("Last updated: %v (source: %v)", time.Now().In(s.opts.Location).Format("2006-01-02 15:04 MST"), s.source())
+}
+
+// The template is checked by New, so only executing it can fail here.
+func (s *Syncer) renderTitle(tableCnt int) (string, error) {
+	tmpl, err := template.New("title").Parse(s.opts.Title)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().In(s.opts.Location)
+	b := strings.Builder{}
+	err = tmpl.Execute(&b, titleData{
+		Now:    now,
+		Date:   now.Format("2006-01-02"),
+		Time:   now.Format("15:04"),
+		URL:    s.source(),
+		Tables: tableCnt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to render the title: %v", err)
+	}
+
+	return b.String(), nil
+}
+
+// Returns the insert options of the table, Insert with the fields that differ between the tables set.
+func (s *Syncer) tableOptions(tableIdx int, tbl scrape.Table) gdocs.InsertOptions {
+	opts := s.opts.Insert
+	// When appending, the first table is separated from the content that was already there.
+	opts.Separator = tableIdx > 0 || s.opts.Append
+	opts.Section = ""
+	if s.opts.PageHeadings {
+		opts.Section = tbl.Heading
+	}
+	opts.Heading = strings.ReplaceAll(s.opts.Insert.Heading, "{n}", strconv.Itoa(tableIdx+1))
+	opts.Caption = tbl.Caption
+	opts.Preamble = ""
+	opts.Logger = s.opts.Logger
+	return opts
+}
+
+// Creates the document, in DriveFolderId if it is set.
+func (s *Syncer) createDocument(ctx context.Context, srv *docs.Service, title string) (*docs.Document, error) {
+	if s.opts.DriveFolderId == "" {
+		return gdocs.CreateDocument(ctx, srv, s.opts.DocumentIdPath, s.opts.URL, title)
+	}
+
+	driveSrv, err := gdocs.GetDriveService(ctx, s.opts.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return gdocs.CreateDocumentInFolder(ctx, srv, driveSrv, s.opts.DocumentIdPath, s.opts.URL, title, s.opts.DriveFolderId)
+}
+
+// A failed rename leaves the old title, which isn't worth failing the write for.
+func (s *Syncer) rename(ctx context.Context, doc *docs.Document, title string) {
+	driveSrv, err := gdocs.GetDriveService(ctx, s.opts.Auth)
+	if err == nil {
+		err = gdocs.RenameDocument(ctx, driveSrv, doc.DocumentId, title)
+	}
+	if err != nil {
+		s.opts.Logger.Error("Failed to rename document", "err", err)
+	} else {
+		s.opts.Logger.Info("Renamed document", "document_id", doc.DocumentId, "title", title)
+	}
+}
+
+// Asks Confirm before clearing a document that has content. A mistyped document id would otherwise
+// wipe someone else's document.
+func (s *Syncer) confirmClear(ctx context.Context, srv *docs.Service, doc *docs.Document) error {
+	if s.opts.Confirm == nil {
+		return nil
+	}
+
+	length, err := gdocs.DocumentLength(ctx, doc.DocumentId, srv)
+	if err != nil {
+		return stepError(ErrWrite, fmt.Errorf("Failed to get document length: %w", err))
+	}
+	if length == 0 {
+		return nil
+	}
+	return s.opts.Confirm(doc, length)
+}
+
+// Failing to save the hash only costs a rewrite on the next run, so it isn't an error.
+func (s *Syncer) storeHash(docId string, hash string) {
+	if s.opts.HashPath == "" {
+		return
+	}
+	if err := saveHash(s.opts.HashPath, docId, hash); err != nil {
+		s.opts.Logger.Warn("Unable to save the hash of the tables, the next run will rewrite them", "err", err)
+	}
+}
+
+// Clears the stored document doc after Confirm, without scraping anything.
+func (s *Syncer) Clear(ctx context.Context, srv *docs.Service, doc *docs.Document) error {
+	if err := s.confirmClear(ctx, srv, doc); err != nil {
+		return err
+	}
+	if err := gdocs.ClearDocument(ctx, doc.DocumentId, srv); err != nil {
+		return stepError(ErrWrite, fmt.Errorf("Failed to clear document: %w", err))
+	}
+	// Otherwise the next run would take the tables for unchanged and leave the document empty.
+	s.storeHash(doc.DocumentId, "")
+	s.opts.Logger.Info("Cleared document", "document_id", doc.DocumentId)
+	return nil
+}
+
+// Writes the tables to doc, the document OpenDocument returned, or to a new document if it is nil.
+// The empty layout tables are left out. Unless appending or filling placeholders, a stored document is
+// cleared first, after Confirm. Tables unchanged since they were last written to the document are skipped.
+func (s *Syncer) Write(ctx context.Context, srv *docs.Service, doc *docs.Document, tables []scrape.Table) (Result, error) {
+	logger := s.opts.Logger
+	result := Result{Found: len(tables)}
+
+	nonEmpty := []scrape.Table{}
+	for i, tbl := range tables {
+		if tbl.IsEmpty() {
+			logger.Debug("Skipping empty table", "index", i)
+			continue
+		}
+		nonEmpty = append(nonEmpty, tbl)
+	}
+
+	// Checked before creating a document, so that a new one isn't left empty. The content of the document
+	// is only kept when appending or filling placeholders.
+	var kept *docs.Document
+	if s.opts.Append || s.opts.Placeholder != "" {
+		kept = doc
+	}
+	if err := gdocs.CheckSize(nonEmpty, kept); err != nil {
+		return result, stepError(ErrWrite, err)
+	}
+
+	title, err := s.renderTitle(len(tables))
+	if err != nil {
+		return result, fmt.Errorf("Failed to render document title: %w", err)
+	}
+
+	stored := doc != nil
+	if doc == nil {
+		doc, err = s.createDocument(ctx, srv, title)
+		if err != nil {
+			return result, stepError(ErrDocument, fmt.Errorf("Failed to create document: %w", err))
+		}
+		result.Created = true
+		logger.Info("Created document", "document_id", doc.DocumentId, "title", title)
+	} else if s.opts.Rename && doc.Title != title {
+		s.rename(ctx, doc, title)
+	}
+	result.DocumentId = doc.DocumentId
+
+	insertOptions := make([]gdocs.InsertOptions, len(nonEmpty))
+	for i, tbl := range nonEmpty {
+		insertOptions[i] = s.tableOptions(i, tbl)
+	}
+	if s.opts.Timestamp && len(insertOptions) > 0 {
+		insertOptions[0].Preamble = s.timestampLine()
+	}
+
+	// An unchanged page isn't written again, which saves API quota and keeps the revision history clean.
+	hash, err := tablesHash(nonEmpty, insertOptions, s.opts.Toc)
+	if err != nil {
+		return result, fmt.Errorf("Failed to hash tables: %w", err)
+	}
+	if s.opts.HashPath != "" {
+		hashes, err := loadHashes(s.opts.HashPath)
+		if err != nil {
+			logger.Warn("Unable to load the hashes of the previous runs", "err", err)
+		}
+		if !s.opts.Force && hashes[doc.DocumentId] == hash {
+			logger.Info("The tables haven't changed since the last run, set Force to rewrite them", "document_id", doc.DocumentId)
+			result.Unchanged = true
+			return result, nil
+		}
+	}
+
+	if s.opts.Placeholder != "" {
+		result.Inserted, err = gdocs.InsertTablesAtPlaceholders(ctx, doc.DocumentId, srv, nonEmpty, insertOptions, s.opts.Placeholder)
+		logger.Info("Inserted tables", "inserted", result.Inserted, "skipped", len(tables)-result.Inserted)
+		if err != nil {
+			return result, stepError(ErrWrite, fmt.Errorf("Failed to insert tables: %w", err))
+		}
+		s.storeHash(doc.DocumentId, hash)
+		return result, nil
+	}
+
+	// The tables an interrupted run already inserted stay, so the document isn't cleared again.
+	var done map[int]bool
+	if s.opts.Resume {
+		done, err = loadResumeLog(s.opts.ResumePath, doc.DocumentId, hash)
+		if err != nil {
+			return result, err
+		}
+		if len(done) > 0 {
+			logger.Info("Resuming an interrupted run", "inserted", len(done), "left", len(nonEmpty)-len(done))
+		}
+	}
+
+	// A failed clear still leaves the tables worth inserting, the write fails at the end anyway.
+	var clearErr error
+	if !s.opts.Append && len(done) == 0 {
+		if stored {
+			if err := s.confirmClear(ctx, srv, doc); err != nil {
+				return result, err
+			}
+		}
+
+		err = gdocs.ClearDocument(ctx, doc.DocumentId, srv)
+		if ctx.Err() != nil {
+			return result, fmt.Errorf("Failed to clear document: %w", ctx.Err())
+		}
+		if err != nil {
+			logger.Error("Failed to clear document", "err", err)
+			clearErr = fmt.Errorf("Failed to clear document: %w", err)
+		}
+	}
+
+	if s.opts.Resume {
+		result.Inserted, err = s.insertResumable(ctx, srv, doc, nonEmpty, insertOptions, hash, done)
+	} else {
+		result.Inserted, err = gdocs.InsertTablesToDocument(ctx, doc.DocumentId, srv, nonEmpty, insertOptions)
+	}
+	logger.Info("Inserted tables", "inserted", result.Inserted, "skipped", len(tables)-result.Inserted)
+	if err != nil {
+		return result, stepError(ErrWrite, errors.Join(clearErr, fmt.Errorf("Failed to insert tables: %w", err)))
+	}
+	// Even after a failed clear, as the next run has to clear the document and insert all the tables again.
+	if s.opts.Resume {
+		if err := clearResumeLog(s.opts.ResumePath, doc.DocumentId); err != nil {
+			logger.Warn("Unable to clear the resume log, delete it before the next run", "err", err)
+		}
+	}
+	if clearErr != nil {
+		return result, stepError(ErrWrite, clearErr)
+	}
+
+	if s.opts.Toc {
+		entries, err := gdocs.InsertTableOfContents(ctx, doc.DocumentId, srv)
+		if err != nil {
+			return result, stepError(ErrWrite, fmt.Errorf("Failed to insert table of contents: %w", err))
+		}
+		result.TocEntries = entries
+		if entries == 0 {
+			logger.Warn("The document has no headings for the table of contents, set Insert.Heading or PageHeadings")
+		} else {
+			logger.Info("Inserted table of contents", "entries", entries)
+		}
+	}
+
+	s.storeHash(doc.DocumentId, hash)
+	return result, nil
+}